@@ -15,8 +15,10 @@ import (
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/auth"
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/bucket"
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/events"
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/file"
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/quiz"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/storage"
 )
 
 func main() {
@@ -35,27 +37,39 @@ func main() {
 	}
 	ai.InitOpenAI(openaiKey)
 
-	// 4) Initialize GORM + Postgres
+	// 4) Initialize GORM + Postgres, file storage and status events
 	db.InitDB()
+	storage.InitStorage()
+	events.Init()
 
 	// 5) Auto-migrate all models:
 	//    - User (auth)
 	//    - Bucket (bucket)
 	//    - File and FileChunk (file)
-	//    - Quiz, Question, Answer, Attempt, AttemptAnswer (quiz)
+	//    - Quiz, Question, Answer, Attempt, AttemptAnswer, LearnerAbility (quiz)
+	if err := file.DedupeChunks(); err != nil {
+		log.Fatal("Deduplicating file chunks failed:", err)
+	}
 	if err := db.DB.AutoMigrate(
 		&auth.User{},
 		&bucket.Bucket{},
 		&file.File{},
 		&file.FileChunk{},
+		&file.NoteLink{},
+		&file.UploadSession{},
+		&file.UploadPart{},
 		&quiz.Quiz{},
 		&quiz.Question{},
 		&quiz.Answer{},
 		&quiz.Attempt{},
 		&quiz.AttemptAnswer{},
+		&quiz.LearnerAbility{},
 	); err != nil {
 		log.Fatal("AutoMigrate models failed:", err)
 	}
+	if err := file.BackfillStorageKeys(); err != nil {
+		log.Fatal("Backfilling storage keys failed:", err)
+	}
 
 	mux := http.NewServeMux()
 
@@ -70,8 +84,14 @@ func main() {
 	// Quiz‐specific routes:
 	mux.Handle("/quizzes/", auth.AuthMiddleware(http.HandlerFunc(handleQuizzesRoot)))
 
-	// Attempt‐detail route:
-	mux.Handle("/attempts/", auth.AuthMiddleware(http.HandlerFunc(quiz.GetAttemptDetailsHandler)))
+	// Attempt routes (details + adaptive question flow):
+	mux.Handle("/attempts/", auth.AuthMiddleware(http.HandlerFunc(handleAttemptsRoot)))
+
+	// Learner progress dashboard:
+	mux.Handle("/me/progress", auth.AuthMiddleware(http.HandlerFunc(quiz.GetProgressHandler)))
+
+	// Live file and quiz status updates (Server-Sent Events):
+	mux.Handle("/events", auth.StreamAuthMiddleware(http.HandlerFunc(events.EventsHandler)))
 
 	// Protected ping (example)
 	mux.Handle("/ping", auth.AuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -101,6 +121,19 @@ func main() {
 //   - GET    /buckets                → ListBucketsHandler
 //   - POST   /buckets/{id}/files     → UploadFileHandler
 //   - GET    /buckets/{id}/files     → ListFilesHandler
+//   - POST   /buckets/{id}/sources/url → AddURLSourceHandler
+//   - POST   /buckets/{id}/sources/vault → ImportVaultHandler
+//   - POST   /buckets/{id}/files/{fileId}/refresh → RefreshFileHandler
+//   - DELETE /buckets/{id}/files/{fileId} → DeleteFileHandler
+//   - PUT    /buckets/{id}/files/{fileId} → ReplaceFileHandler
+//   - POST   /buckets/{id}/files/{fileId}/reprocess → ReprocessFileHandler
+//   - GET    /buckets/{id}/files/{fileId}/content → FileContentHandler
+//   - GET    /buckets/{id}/files/{fileId}/chunks  → ListChunksHandler
+//   - POST   /buckets/{id}/uploads   → CreateUploadHandler
+//   - GET    /buckets/{id}/uploads/{uploadId} → GetUploadHandler (also HEAD)
+//   - PATCH  /buckets/{id}/uploads/{uploadId} → PatchUploadHandler
+//   - DELETE /buckets/{id}/uploads/{uploadId} → CancelUploadHandler
+//   - POST   /buckets/{id}/uploads/{uploadId}/complete → CompleteUploadHandler
 //   - POST   /buckets/{id}/quizzes   → CreateQuizHandler
 //   - GET    /buckets/{id}/attempts  → ListAttemptsHandler
 func handleBucketsRoot(w http.ResponseWriter, r *http.Request) {
@@ -131,6 +164,82 @@ func handleBucketsRoot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 4b) POST  /buckets/{id}/sources/url
+	if strings.HasPrefix(path, "/buckets/") && strings.HasSuffix(path, "/sources/url") && method == http.MethodPost {
+		file.AddURLSourceHandler(w, r)
+		return
+	}
+
+	// 4c) POST  /buckets/{id}/sources/vault
+	if strings.HasPrefix(path, "/buckets/") && strings.HasSuffix(path, "/sources/vault") && method == http.MethodPost {
+		file.ImportVaultHandler(w, r)
+		return
+	}
+
+	// 4d) POST  /buckets/{id}/files/{fileId}/refresh
+	if strings.HasPrefix(path, "/buckets/") && strings.Contains(path, "/files/") && strings.HasSuffix(path, "/refresh") && method == http.MethodPost {
+		file.RefreshFileHandler(w, r)
+		return
+	}
+
+	// 4e) POST  /buckets/{id}/files/{fileId}/reprocess
+	if strings.HasPrefix(path, "/buckets/") && strings.Contains(path, "/files/") && strings.HasSuffix(path, "/reprocess") && method == http.MethodPost {
+		file.ReprocessFileHandler(w, r)
+		return
+	}
+
+	// 4f) DELETE /buckets/{id}/files/{fileId}
+	//     PUT    /buckets/{id}/files/{fileId}
+	if segments := strings.Split(path, "/"); len(segments) == 5 && segments[1] == "buckets" && segments[3] == "files" {
+		switch method {
+		case http.MethodDelete:
+			file.DeleteFileHandler(w, r)
+			return
+		case http.MethodPut:
+			file.ReplaceFileHandler(w, r)
+			return
+		}
+	}
+
+	// 4g) GET   /buckets/{id}/files/{fileId}/content
+	if strings.HasPrefix(path, "/buckets/") && strings.Contains(path, "/files/") && strings.HasSuffix(path, "/content") && (method == http.MethodGet || method == http.MethodHead) {
+		file.FileContentHandler(w, r)
+		return
+	}
+
+	// 4h) GET   /buckets/{id}/files/{fileId}/chunks
+	if strings.HasPrefix(path, "/buckets/") && strings.Contains(path, "/files/") && strings.HasSuffix(path, "/chunks") && method == http.MethodGet {
+		file.ListChunksHandler(w, r)
+		return
+	}
+
+	// 4i) POST  /buckets/{id}/uploads
+	if strings.HasPrefix(path, "/buckets/") && strings.HasSuffix(path, "/uploads") && method == http.MethodPost {
+		file.CreateUploadHandler(w, r)
+		return
+	}
+
+	// 4j) POST  /buckets/{id}/uploads/{uploadId}/complete
+	if strings.HasPrefix(path, "/buckets/") && strings.Contains(path, "/uploads/") && strings.HasSuffix(path, "/complete") && method == http.MethodPost {
+		file.CompleteUploadHandler(w, r)
+		return
+	}
+
+	// 4k) GET/HEAD, PATCH, DELETE /buckets/{id}/uploads/{uploadId}
+	if segments := strings.Split(path, "/"); len(segments) == 5 && segments[1] == "buckets" && segments[3] == "uploads" {
+		switch method {
+		case http.MethodGet, http.MethodHead:
+			file.GetUploadHandler(w, r)
+			return
+		case http.MethodPatch:
+			file.PatchUploadHandler(w, r)
+			return
+		case http.MethodDelete:
+			file.CancelUploadHandler(w, r)
+			return
+		}
+	}
+
 	// 5) POST   /buckets/{id}/quizzes
 	if strings.HasPrefix(path, "/buckets/") && strings.HasSuffix(path, "/quizzes") && method == http.MethodPost {
 		quiz.CreateQuizHandler(w, r)
@@ -149,7 +258,8 @@ func handleBucketsRoot(w http.ResponseWriter, r *http.Request) {
 // handleQuizzesRoot dispatches:
 //   - GET  /quizzes/{quizId}           → GetQuizStatusHandler
 //   - GET  /quizzes/{quizId}/questions → GetQuizQuestionsHandler
-//   - POST /quizzes/{quizId}/attempts  → SubmitQuizHandler
+//   - POST /quizzes/{quizId}/attempts  → SubmitQuizHandler (starts an attempt for adaptive quizzes)
+//   - GET  /quizzes/{quizId}/analytics → GetQuizAnalyticsHandler
 func handleQuizzesRoot(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 	method := r.Method
@@ -172,5 +282,40 @@ func handleQuizzesRoot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// GET /quizzes/{quizId}/analytics
+	if strings.HasPrefix(path, "/quizzes/") && strings.HasSuffix(path, "/analytics") && method == http.MethodGet {
+		quiz.GetQuizAnalyticsHandler(w, r)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// handleAttemptsRoot dispatches:
+//   - GET  /attempts/{attemptId}         → GetAttemptDetailsHandler
+//   - GET  /attempts/{attemptId}/next    → GetNextQuestionHandler
+//   - POST /attempts/{attemptId}/answers → SubmitAdaptiveAnswerHandler
+func handleAttemptsRoot(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	method := r.Method
+
+	// GET /attempts/{attemptId}
+	if segments := strings.Split(path, "/"); len(segments) == 3 && segments[1] == "attempts" && method == http.MethodGet {
+		quiz.GetAttemptDetailsHandler(w, r)
+		return
+	}
+
+	// GET /attempts/{attemptId}/next
+	if strings.HasPrefix(path, "/attempts/") && strings.HasSuffix(path, "/next") && method == http.MethodGet {
+		quiz.GetNextQuestionHandler(w, r)
+		return
+	}
+
+	// POST /attempts/{attemptId}/answers
+	if strings.HasPrefix(path, "/attempts/") && strings.HasSuffix(path, "/answers") && method == http.MethodPost {
+		quiz.SubmitAdaptiveAnswerHandler(w, r)
+		return
+	}
+
 	http.NotFound(w, r)
 }
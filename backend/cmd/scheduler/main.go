@@ -0,0 +1,53 @@
+// cmd/scheduler/main.go
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/joho/godotenv"
+)
+
+// The scheduler enqueues the periodic jobs that the workers run. Unlike
+// the workers, exactly one of it must be running, or every job would be
+// enqueued once per instance.
+func main() {
+	// 1) Load .env so REDIS_ADDR and CALIBRATION_SCHEDULE are available
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, continuing with environment variables")
+	}
+
+	// 2) Get Redis address from env
+	redisAddr := os.Getenv("REDIS_ADDR")
+	if redisAddr == "" {
+		log.Fatal("REDIS_ADDR must be set")
+	}
+
+	// 3) Register periodic jobs (CALIBRATION_SCHEDULE is a cron spec or "@every <duration>")
+	calibrationSchedule := os.Getenv("CALIBRATION_SCHEDULE")
+	if calibrationSchedule == "" {
+		calibrationSchedule = "@every 6h"
+	}
+	scheduler := asynq.NewScheduler(asynq.RedisClientOpt{Addr: redisAddr}, nil)
+	if _, err := scheduler.Register(
+		calibrationSchedule,
+		asynq.NewTask("CalibrateItems", nil),
+		asynq.Unique(time.Hour),
+	); err != nil {
+		log.Fatalf("could not schedule CalibrateItems: %v", err)
+	}
+	if _, err := scheduler.Register(
+		"@every 1h",
+		asynq.NewTask("ExpireUploads", nil),
+		asynq.Unique(30*time.Minute),
+	); err != nil {
+		log.Fatalf("could not schedule ExpireUploads: %v", err)
+	}
+
+	// 4) Run until interrupted
+	if err := scheduler.Run(); err != nil {
+		log.Fatalf("Asynq scheduler failed: %v", err)
+	}
+}
@@ -4,6 +4,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"os"
 
@@ -12,8 +13,10 @@ import (
 
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/ai"
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/events"
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/file"
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/quiz"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/storage"
 )
 
 func main() {
@@ -29,8 +32,10 @@ func main() {
 	}
 	ai.InitOpenAI(openaiKey)
 
-	// 3) Connect to Postgres
+	// 3) Connect to Postgres, file storage and status events
 	db.InitDB()
+	storage.InitStorage()
+	events.Init()
 
 	// 4) Get Redis address from env
 	redisAddr := os.Getenv("REDIS_ADDR")
@@ -60,10 +65,20 @@ func main() {
 		}
 		log.Printf("Worker: starting ProcessFile for file_id=%d\n", payload.FileID)
 
-		// Delegate to the file service
-		file.ProcessFile(payload.FileID)
+		// Delegate to the file service. It is idempotent, so a retry picks up
+		// where a failed or interrupted run left off; the file is only marked
+		// failed once the last retry has failed too.
+		if err := file.ProcessFile(ctx, payload.FileID); err != nil {
+			retried, _ := asynq.GetRetryCount(ctx)
+			maxRetry, _ := asynq.GetMaxRetry(ctx)
+			if retried >= maxRetry && !errors.Is(err, asynq.SkipRetry) {
+				file.FailFile(payload.FileID, err)
+			}
+			log.Printf("Worker: ProcessFile error for file_id=%d (attempt %d/%d): %v\n", payload.FileID, retried+1, maxRetry+1, err)
+			return err
+		}
 
-		log.Printf("Worker: finished ProcessFile (service) for file_id=%d\n", payload.FileID)
+		log.Printf("Worker: finished ProcessFile for file_id=%d\n", payload.FileID)
 		return nil
 	})
 
@@ -89,7 +104,28 @@ func main() {
 		return nil
 	})
 
-	// 7) Run the Asynq server
+	// ─── CalibrateItems ─────────────────────────────────────────────────────────
+	mux.HandleFunc("CalibrateItems", func(ctx context.Context, t *asynq.Task) error {
+		log.Printf("Worker: starting CalibrateItems\n")
+		if err := quiz.CalibrateItems(); err != nil {
+			log.Printf("Worker: CalibrateItems error: %v\n", err)
+			return err
+		}
+		log.Printf("Worker: finished CalibrateItems\n")
+		return nil
+	})
+
+	// ─── ExpireUploads ──────────────────────────────────────────────────────────
+	mux.HandleFunc("ExpireUploads", func(ctx context.Context, t *asynq.Task) error {
+		if err := file.ExpireUploadSessions(); err != nil {
+			log.Printf("Worker: ExpireUploads error: %v\n", err)
+			return err
+		}
+		return nil
+	})
+
+	// 7) Run the Asynq server. Periodic jobs (CalibrateItems, ExpireUploads)
+	// are enqueued by cmd/scheduler, so any number of workers can run.
 	if err := srv.Run(mux); err != nil {
 		log.Fatalf("Asynq server failed: %v", err)
 	}
@@ -39,6 +39,34 @@ func GetEmbedding(text string) ([]float32, error) {
 	return resp.Data[0].Embedding, nil
 }
 
+// GetEmbeddings embeds several texts with one AdaEmbeddingV2 request and
+// returns their embeddings in the same order.
+func GetEmbeddings(texts []string) ([][]float32, error) {
+	if OpenAIClient == nil {
+		return nil, fmt.Errorf("OpenAI client not initialized")
+	}
+	ctx := context.Background()
+	req := goopenai.EmbeddingRequest{
+		Model: goopenai.AdaEmbeddingV2,
+		Input: texts,
+	}
+	resp, err := OpenAIClient.CreateEmbeddings(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("CreateEmbeddings error: %w", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d inputs", len(resp.Data), len(texts))
+	}
+	out := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		out[d.Index] = d.Embedding
+	}
+	return out, nil
+}
+
 // GenerateBucketName takes up to the first few chunks (concatenated)
 // and returns a short, descriptive bucket name.
 func GenerateBucketName(chunks string) (string, error) {
@@ -68,17 +96,33 @@ func GenerateBucketName(chunks string) (string, error) {
 
 // GenerateQuestions takes a long context string, desired question count and choice count,
 // and returns a JSON string that the caller should parse into question objects.
-func GenerateQuestions(contextText string, questionCount int, choiceCount int, difficulty string) (string, error) {
+// Each object has the shape:
+//
+//	{"question": "...", "explanation": "...",
+//	 "choices": [{"text": "...", "correct": true, "explanation": "..."}]}
+//
+// Questions listed in `avoid` are shown to the model as ones it must not repeat or paraphrase.
+func GenerateQuestions(contextText string, questionCount int, choiceCount int, difficulty string, avoid []string) (string, error) {
 	if OpenAIClient == nil {
 		return "", fmt.Errorf("OpenAI client not initialized")
 	}
 	ctx := context.Background()
 	prompt := fmt.Sprintf(
 		"Generate %d multiple-choice questions (each with %d answer choices, one correct) from the following context. "+
-			"Include an explanation for each question and each choice. Output as valid JSON array of objects. "+
-			"Context:\n\n%s",
-		questionCount, choiceCount, contextText,
+			"Include an explanation for each question and each choice. Output as valid JSON array of objects "+
+			`with the keys "question", "explanation" and "choices", where each choice has the keys "text", "correct" and "explanation". `,
+		questionCount, choiceCount,
 	)
+	if difficulty != "" {
+		prompt += fmt.Sprintf("The questions should be of %s difficulty. ", difficulty)
+	}
+	if len(avoid) > 0 {
+		prompt += "Do not repeat or paraphrase any of these existing questions; test the same concepts from a different angle:\n"
+		for _, q := range avoid {
+			prompt += "- " + q + "\n"
+		}
+	}
+	prompt += "\nContext:\n\n" + contextText
 
 	req := goopenai.ChatCompletionRequest{
 		Model: goopenai.GPT4,
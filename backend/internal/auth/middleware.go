@@ -23,19 +23,41 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		tokenStr := strings.TrimPrefix(header, "Bearer ")
-		claims, err := ParseToken(tokenStr)
-		if err != nil {
-			http.Error(w, "invalid token", http.StatusUnauthorized)
+		serveWithToken(w, r, next, strings.TrimPrefix(header, "Bearer "))
+	})
+}
+
+// StreamAuthMiddleware is AuthMiddleware for event streams. Browsers can't
+// set headers on an EventSource, so the token may instead be passed as the
+// access_token query parameter.
+func StreamAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+			serveWithToken(w, r, next, strings.TrimPrefix(header, "Bearer "))
 			return
 		}
-
-		// Store *Claims in context for later handlers
-		ctx := context.WithValue(r.Context(), userContextKey, claims)
-		next.ServeHTTP(w, r.WithContext(ctx))
+		tokenStr := r.URL.Query().Get("access_token")
+		if tokenStr == "" {
+			http.Error(w, "missing Authorization header or access_token", http.StatusUnauthorized)
+			return
+		}
+		serveWithToken(w, r, next, tokenStr)
 	})
 }
 
+// serveWithToken validates tokenStr and calls next with its claims.
+func serveWithToken(w http.ResponseWriter, r *http.Request, next http.Handler, tokenStr string) {
+	claims, err := ParseToken(tokenStr)
+	if err != nil {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	// Store *Claims in context for later handlers
+	ctx := context.WithValue(r.Context(), userContextKey, claims)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
 // FromContext retrieves the JWT *Claims that were stored by AuthMiddleware.
 // Returns (claims, true) if found, or (nil, false) otherwise.
 func FromContext(ctx context.Context) (*Claims, bool) {
@@ -0,0 +1,146 @@
+// internal/events/events.go
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Events tell clients that a file or quiz changed status, so they don't
+// have to poll. Whoever makes a change (the API or a worker) publishes it
+// for the bucket it belongs to: it is appended to the bucket's Redis
+// stream, which keeps recent events for clients resuming after a dropped
+// connection, and announced on the bucket's pub/sub channel, which every
+// API instance listens on to fan it out to connected clients.
+
+const (
+	// streamMaxLen is roughly how many recent events a bucket keeps for
+	// resuming clients.
+	streamMaxLen = 1000
+	// streamTTL is how long a bucket's stream outlives its last event.
+	streamTTL = 24 * time.Hour
+	// publishTimeout bounds a publish, so a slow Redis never holds up
+	// processing.
+	publishTimeout = 2 * time.Second
+	channelPrefix  = "events:bucket:"
+)
+
+// Event is one change, sent to clients as an SSE message named Type.
+type Event struct {
+	ID       string          `json:"id"`   // stream entry ID, the SSE id
+	Type     string          `json:"type"` // "file" or "quiz"
+	BucketID uint            `json:"bucketId"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// rdb is nil when REDIS_ADDR isn't set; events are then not published.
+var rdb *redis.Client
+
+// Init connects to REDIS_ADDR. Must be called after godotenv.Load().
+func Init() {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		log.Printf("⚠️  Redis not configured (REDIS_ADDR empty); status events are disabled")
+		return
+	}
+	rdb = redis.NewClient(&redis.Options{Addr: addr})
+}
+
+// Enabled reports whether events can be published and received.
+func Enabled() bool {
+	return rdb != nil
+}
+
+func streamKey(bucketID uint) string {
+	return fmt.Sprintf("events:stream:%d", bucketID)
+}
+
+func channel(bucketID uint) string {
+	return channelPrefix + strconv.FormatUint(uint64(bucketID), 10)
+}
+
+// Publish announces a change of type typ in a bucket; data is sent to
+// clients as JSON. Failures are logged, never returned: events are a
+// convenience, and clients can always fall back to polling.
+func Publish(bucketID uint, typ string, data interface{}) {
+	if rdb == nil {
+		return
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("[events] could not encode %s event: %v\n", typ, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	key := streamKey(bucketID)
+	id, err := rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: streamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"type": typ, "data": payload},
+	}).Result()
+	if err != nil {
+		log.Printf("[events] could not record %s event for bucket %d: %v\n", typ, bucketID, err)
+		return
+	}
+	rdb.Expire(ctx, key, streamTTL)
+
+	msg, _ := json.Marshal(Event{ID: id, Type: typ, BucketID: bucketID, Data: payload})
+	if err := rdb.Publish(ctx, channel(bucketID), msg).Err(); err != nil {
+		log.Printf("[events] could not publish %s event for bucket %d: %v\n", typ, bucketID, err)
+	}
+}
+
+// since returns a bucket's events after lastID, oldest first. complete is
+// false when lastID has already been trimmed from the stream, so events
+// may have been missed.
+func since(ctx context.Context, bucketID uint, lastID string) (evs []Event, complete bool, err error) {
+	msgs, err := rdb.XRange(ctx, streamKey(bucketID), lastID, "+").Result()
+	if err != nil {
+		return nil, false, err
+	}
+	evs, complete = replay(msgs, bucketID, lastID)
+	return evs, complete, nil
+}
+
+// replay turns the stream entries from lastID on into the events that
+// follow it. The range starts at lastID itself, so it is complete only if
+// that entry is still there; otherwise the stream was trimmed past it (or
+// the ID was never in it) and events may be missing.
+func replay(msgs []redis.XMessage, bucketID uint, lastID string) (evs []Event, complete bool) {
+	complete = len(msgs) > 0 && msgs[0].ID == lastID
+	for _, m := range msgs {
+		if m.ID == lastID {
+			continue
+		}
+		typ, _ := m.Values["type"].(string)
+		data, _ := m.Values["data"].(string)
+		evs = append(evs, Event{ID: m.ID, Type: typ, BucketID: bucketID, Data: json.RawMessage(data)})
+	}
+	return evs, complete
+}
+
+// after reports whether stream entry ID a comes after b. IDs look like
+// "<milliseconds>-<sequence>".
+func after(a, b string) bool {
+	am, as := splitID(a)
+	bm, bs := splitID(b)
+	return am > bm || (am == bm && as > bs)
+}
+
+func splitID(id string) (ms, seq uint64) {
+	m, s, _ := strings.Cut(id, "-")
+	ms, _ = strconv.ParseUint(m, 10, 64)
+	seq, _ = strconv.ParseUint(s, 10, 64)
+	return ms, seq
+}
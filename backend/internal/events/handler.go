@@ -0,0 +1,104 @@
+// internal/events/handler.go
+package events
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/auth"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/bucket"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+)
+
+// heartbeatInterval keeps idle connections from being closed by proxies.
+const heartbeatInterval = 15 * time.Second
+
+// GET /events?bucket={bucketId}
+// Streams Server-Sent Events for the bucket's files and quizzes: "file"
+// and "quiz" messages whose data is the item's current status, plus a
+// comment line every 15 seconds. A reconnecting client sends the last id
+// it saw (the Last-Event-ID header, or the lastEventId query parameter)
+// and gets what it missed; if that is too old, a "resync" message tells
+// it to reload the bucket instead.
+func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	bucketID, err := strconv.ParseUint(r.URL.Query().Get("bucket"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid bucket ID", http.StatusBadRequest)
+		return
+	}
+	var b bucket.Bucket
+	if err := db.DB.
+		Where("id = ? AND user_id = ?", bucketID, claims.UserID).
+		First(&b).Error; err != nil {
+		http.Error(w, "bucket not found", http.StatusNotFound)
+		return
+	}
+	if !Enabled() {
+		http.Error(w, "events are not available", http.StatusServiceUnavailable)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe before replaying, so nothing published in between is lost;
+	// live events already replayed are skipped by ID.
+	sub := subscribe(uint(bucketID))
+	defer unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	if lastID != "" {
+		missed, complete, err := since(r.Context(), uint(bucketID), lastID)
+		if err != nil || !complete {
+			fmt.Fprint(w, "event: resync\ndata: {}\n\n")
+		}
+		for _, ev := range missed {
+			writeEvent(w, ev)
+			lastID = ev.ID
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case ev, ok := <-sub.C:
+			if !ok {
+				return // fell behind; the client reconnects and resumes
+			}
+			if lastID != "" && !after(ev.ID, lastID) {
+				continue
+			}
+			writeEvent(w, ev)
+			lastID = ev.ID
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, ev Event) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, ev.Data)
+}
@@ -0,0 +1,112 @@
+// internal/events/hub.go
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is how many events a slow client may fall behind
+// before it is dropped (it then reconnects and resumes from the stream).
+const subscriberBuffer = 64
+
+// subscription receives the events of one bucket. C is closed when the
+// subscriber falls too far behind.
+type subscription struct {
+	bucketID uint
+	C        chan Event
+}
+
+// hub fans the pub/sub messages of all buckets out to this instance's
+// subscribers over a single Redis connection, started on first use.
+type hub struct {
+	mu   sync.Mutex
+	subs map[uint]map[*subscription]struct{}
+}
+
+var (
+	hubOnce sync.Once
+	theHub  *hub
+)
+
+func getHub() *hub {
+	hubOnce.Do(func() {
+		theHub = &hub{subs: map[uint]map[*subscription]struct{}{}}
+		go theHub.run()
+	})
+	return theHub
+}
+
+// run relays messages for as long as the process lives, re-subscribing
+// after a lost connection.
+func (h *hub) run() {
+	for {
+		ps := rdb.PSubscribe(context.Background(), channelPrefix+"*")
+		for msg := range ps.Channel() {
+			bucketID, err := strconv.ParseUint(strings.TrimPrefix(msg.Channel, channelPrefix), 10, 64)
+			if err != nil {
+				continue
+			}
+			var ev Event
+			if err := json.Unmarshal([]byte(msg.Payload), &ev); err != nil {
+				log.Printf("[events] bad message on %s: %v\n", msg.Channel, err)
+				continue
+			}
+			h.deliver(uint(bucketID), ev)
+		}
+		ps.Close()
+		log.Printf("[events] subscription lost; retrying\n")
+		time.Sleep(time.Second)
+	}
+}
+
+func (h *hub) deliver(bucketID uint, ev Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subs[bucketID] {
+		select {
+		case s.C <- ev:
+		default:
+			delete(h.subs[bucketID], s)
+			close(s.C)
+		}
+	}
+}
+
+// subscribe starts receiving a bucket's events.
+func subscribe(bucketID uint) *subscription {
+	return getHub().add(bucketID)
+}
+
+// unsubscribe stops a subscription, unless the hub already dropped it.
+func unsubscribe(s *subscription) {
+	getHub().remove(s)
+}
+
+func (h *hub) add(bucketID uint) *subscription {
+	s := &subscription{bucketID: bucketID, C: make(chan Event, subscriberBuffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[bucketID] == nil {
+		h.subs[bucketID] = map[*subscription]struct{}{}
+	}
+	h.subs[bucketID][s] = struct{}{}
+	return s
+}
+
+func (h *hub) remove(s *subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[s.bucketID][s]; ok {
+		delete(h.subs[s.bucketID], s)
+		close(s.C)
+	}
+	if len(h.subs[s.bucketID]) == 0 {
+		delete(h.subs, s.bucketID)
+	}
+}
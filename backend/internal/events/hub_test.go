@@ -0,0 +1,182 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestAfter(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1700000000001-0", "1700000000000-0", true},
+		{"1700000000000-1", "1700000000000-0", true},
+		{"1700000000000-0", "1700000000000-0", false},
+		{"1700000000000-0", "1700000000000-1", false},
+		{"999-0", "1000-0", false},
+		{"1000-0", "999-5", true}, // compared as numbers, not strings
+		{"1000-10", "1000-9", true},
+	}
+	for _, tt := range tests {
+		if got := after(tt.a, tt.b); got != tt.want {
+			t.Errorf("after(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func msg(id, typ, data string) redis.XMessage {
+	return redis.XMessage{ID: id, Values: map[string]interface{}{"type": typ, "data": data}}
+}
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name         string
+		msgs         []redis.XMessage
+		lastID       string
+		wantIDs      []string
+		wantComplete bool
+	}{
+		{
+			name:         "resumes after the last seen event",
+			msgs:         []redis.XMessage{msg("5-0", "file", `{}`), msg("6-0", "quiz", `{}`), msg("7-0", "file", `{}`)},
+			lastID:       "5-0",
+			wantIDs:      []string{"6-0", "7-0"},
+			wantComplete: true,
+		},
+		{
+			name:         "nothing missed",
+			msgs:         []redis.XMessage{msg("5-0", "file", `{}`)},
+			lastID:       "5-0",
+			wantComplete: true,
+		},
+		{
+			name:         "trimmed past the last seen event",
+			msgs:         []redis.XMessage{msg("8-0", "file", `{}`), msg("9-0", "file", `{}`)},
+			lastID:       "5-0",
+			wantIDs:      []string{"8-0", "9-0"},
+			wantComplete: false,
+		},
+		{
+			name:         "stream expired",
+			lastID:       "5-0",
+			wantComplete: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evs, complete := replay(tt.msgs, 3, tt.lastID)
+			if complete != tt.wantComplete {
+				t.Errorf("complete = %v, want %v", complete, tt.wantComplete)
+			}
+			var ids []string
+			for _, ev := range evs {
+				ids = append(ids, ev.ID)
+				if ev.BucketID != 3 {
+					t.Errorf("event %s has bucket %d, want 3", ev.ID, ev.BucketID)
+				}
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("replayed %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestReplayKeepsTypeAndData(t *testing.T) {
+	evs, _ := replay([]redis.XMessage{msg("1-0", "x", `{}`), msg("2-0", "quiz", `{"id":4}`)}, 1, "1-0")
+	if len(evs) != 1 || evs[0].Type != "quiz" || string(evs[0].Data) != `{"id":4}` {
+		t.Fatalf("replay = %+v", evs)
+	}
+}
+
+func TestWriteEvent(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeEvent(rec, Event{ID: "12-3", Type: "file", Data: json.RawMessage(`{"id":7}`)})
+	want := "id: 12-3\nevent: file\ndata: {\"id\":7}\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("writeEvent wrote %q, want %q", got, want)
+	}
+}
+
+func newTestHub() *hub {
+	return &hub{subs: map[uint]map[*subscription]struct{}{}}
+}
+
+func TestHubDeliversToBucketSubscribers(t *testing.T) {
+	h := newTestHub()
+	a := h.add(1)
+	b := h.add(1)
+	other := h.add(2)
+
+	h.deliver(1, Event{ID: "1-0"})
+
+	for _, s := range []*subscription{a, b} {
+		select {
+		case ev := <-s.C:
+			if ev.ID != "1-0" {
+				t.Errorf("got event %s, want 1-0", ev.ID)
+			}
+		default:
+			t.Error("subscriber of bucket 1 got nothing")
+		}
+	}
+	select {
+	case ev := <-other.C:
+		t.Errorf("subscriber of bucket 2 got %s", ev.ID)
+	default:
+	}
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	h := newTestHub()
+	slow := h.add(1)
+	for i := 0; i < subscriberBuffer; i++ {
+		h.deliver(1, Event{})
+	}
+	if _, ok := h.subs[1][slow]; !ok {
+		t.Fatal("subscriber dropped before its buffer was full")
+	}
+
+	h.deliver(1, Event{})
+	if _, ok := h.subs[1][slow]; ok {
+		t.Fatal("full subscriber was not dropped")
+	}
+	n := 0
+	for range slow.C {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("drained %d buffered events, want %d", n, subscriberBuffer)
+	}
+
+	// Unsubscribing after the drop must not close the channel again.
+	h.remove(slow)
+	if len(h.subs) != 0 {
+		t.Errorf("hub still tracks buckets %v", h.subs)
+	}
+}
+
+func TestHubRemove(t *testing.T) {
+	h := newTestHub()
+	a := h.add(1)
+	b := h.add(1)
+
+	h.remove(a)
+	if _, ok := <-a.C; ok {
+		t.Error("removed subscription's channel is still open")
+	}
+	h.deliver(1, Event{ID: "2-0"})
+	if ev := <-b.C; ev.ID != "2-0" {
+		t.Errorf("remaining subscriber got %s, want 2-0", ev.ID)
+	}
+
+	h.remove(b)
+	if _, ok := h.subs[1]; ok {
+		t.Error("bucket with no subscribers is still tracked")
+	}
+}
@@ -0,0 +1,184 @@
+// internal/file/archive.go
+package file
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/utils"
+)
+
+const (
+	// maxArchiveEntries caps how many entries (files and directories) an
+	// uploaded archive may have.
+	maxArchiveEntries = 1000
+	// maxArchiveBytes caps the total uncompressed size of an archive.
+	maxArchiveBytes = 500 << 20
+	// maxEntryNameLen matches the size of File.Filename, which holds the
+	// entry's relative path.
+	maxEntryNameLen = 255
+)
+
+var (
+	ErrTooManyEntries  = fmt.Errorf("archive has more than %d entries", maxArchiveEntries)
+	ErrArchiveTooLarge = fmt.Errorf("archive expands to more than %d MB", maxArchiveBytes>>20)
+)
+
+// archiveEntry is a file extracted from an archive.
+type archiveEntry struct {
+	Name        string // cleaned path inside the archive, e.g. "week1/notes.md"
+	Path        string // where it was extracted
+	Size        int64
+	ContentType string // see utils.SniffContentType
+}
+
+// skippedEntry is an archive entry that was not imported, and why.
+type skippedEntry struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// expandArchive extracts the supported documents in the zip at archivePath
+// into destDir, preserving their relative paths. Entries that would escape
+// destDir ("zip slip"), links, hidden/OS metadata files, nested archives and
+// unsupported formats are skipped and reported. The whole expansion fails
+// if the archive exceeds maxArchiveEntries or maxArchiveBytes; the latter is
+// enforced on the bytes actually written, not just the sizes the archive
+// declares. On error nothing is left behind in destDir.
+func expandArchive(archivePath, destDir string) (entries []archiveEntry, skipped []skippedEntry, err error) {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not open archive: %w", err)
+	}
+	defer zr.Close()
+
+	if len(zr.File) > maxArchiveEntries {
+		return nil, nil, ErrTooManyEntries
+	}
+	var declared uint64
+	for _, zf := range zr.File {
+		declared += zf.UncompressedSize64
+	}
+	if declared > maxArchiveBytes {
+		return nil, nil, ErrArchiveTooLarge
+	}
+
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(destDir)
+		}
+	}()
+
+	var written int64
+	for _, zf := range zr.File {
+		name, ok := archiveEntryName(zf.Name)
+		switch {
+		case !ok:
+			skipped = append(skipped, skippedEntry{Path: zf.Name, Reason: "unsafe path"})
+			continue
+		case zf.FileInfo().IsDir():
+			continue
+		case !zf.Mode().IsRegular():
+			skipped = append(skipped, skippedEntry{Path: name, Reason: "not a regular file"})
+			continue
+		case isHiddenEntry(name):
+			continue
+		case len(name) > maxEntryNameLen:
+			skipped = append(skipped, skippedEntry{Path: name, Reason: "path too long"})
+			continue
+		}
+
+		dst := filepath.Join(destDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return nil, nil, err
+		}
+		n, err := extractEntry(zf, dst, maxArchiveBytes-written)
+		written += n
+		if errors.Is(err, fs.ErrExist) {
+			skipped = append(skipped, skippedEntry{Path: name, Reason: "duplicate entry"})
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		format, err := utils.DetectFormat(dst)
+		if err != nil || format == utils.FormatZip {
+			os.Remove(dst)
+			reason := "unsupported file type"
+			if format == utils.FormatZip {
+				reason = "nested archive"
+			}
+			skipped = append(skipped, skippedEntry{Path: name, Reason: reason})
+			continue
+		}
+		entries = append(entries, archiveEntry{Name: name, Path: dst, Size: n, ContentType: sniffFile(dst, name)})
+	}
+	return entries, skipped, nil
+}
+
+// archiveEntryName cleans an entry name into a relative slash path,
+// rejecting absolute paths and anything that climbs out with "..".
+func archiveEntryName(raw string) (string, bool) {
+	name := strings.ReplaceAll(raw, `\`, "/")
+	if strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':') {
+		return "", false
+	}
+	name = path.Clean(name)
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") || !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", false
+	}
+	return name, true
+}
+
+// isHiddenEntry reports dotfiles and the metadata macOS and Windows add to
+// archives (__MACOSX/, .DS_Store, Thumbs.db).
+func isHiddenEntry(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" || part == "Thumbs.db" {
+			return true
+		}
+	}
+	return false
+}
+
+// extractEntry copies one entry to dst, writing at most budget bytes.
+func extractEntry(zf *zip.File, dst string, budget int64) (int64, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return 0, fmt.Errorf("could not read %s: %w", zf.Name, err)
+	}
+	defer rc.Close()
+
+	// O_EXCL: an archive may list the same path twice; keep the first.
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, io.LimitReader(rc, budget+1))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return n, fmt.Errorf("could not extract %s: %w", zf.Name, err)
+	}
+	if n > budget {
+		return n, ErrArchiveTooLarge
+	}
+	return n, nil
+}
+
+// isArchiveError reports whether err is one of the archive limit errors.
+func isArchiveError(err error) bool {
+	return errors.Is(err, ErrTooManyEntries) || errors.Is(err, ErrArchiveTooLarge)
+}
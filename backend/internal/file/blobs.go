@@ -0,0 +1,92 @@
+// internal/file/blobs.go
+package file
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/storage"
+)
+
+// bucketKey is the storage key of a file in a bucket:
+// user_<userID>/bucket_<bucketID>/<name>. name may contain slashes (archive
+// entries keep their relative paths).
+func bucketKey(userID, bucketID uint, name string) string {
+	return path.Join("user_"+strconv.FormatUint(uint64(userID), 10),
+		"bucket_"+strconv.FormatUint(uint64(bucketID), 10), name)
+}
+
+// putHashed streams r to key in storage.Store, returning the hex SHA-256
+// of what was written.
+func putHashed(ctx context.Context, key string, r io.Reader) (string, error) {
+	h := sha256.New()
+	if err := storage.Store.Put(ctx, key, io.TeeReader(r, h)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// putFile copies the local file at p to key.
+func putFile(ctx context.Context, key, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return storage.Store.Put(ctx, key, f)
+}
+
+// deleteBlob removes a blob, logging rather than failing: a leftover blob
+// only wastes space.
+func deleteBlob(key string) {
+	if err := storage.Store.Delete(context.Background(), key); err != nil {
+		log.Printf("could not delete blob %s: %v", key, err)
+	}
+}
+
+// removeBlobIfUnused deletes stored content that no live file refers to any
+// more. Copies of a file (see copyFile) share its content.
+func removeBlobIfUnused(key string) {
+	var n int64
+	if err := db.DB.Model(&File{}).Where("storage_key = ?", key).Count(&n).Error; err != nil {
+		log.Printf("could not check references to %s: %v", key, err)
+		return
+	}
+	if n == 0 {
+		deleteBlob(key)
+	}
+}
+
+// BackfillStorageKeys gives files stored before StorageKey existed the key
+// of their StoragePath relative to FILE_STORAGE_PATH, which is where the
+// local backend finds them. Paths outside it are logged and left alone.
+func BackfillStorageKeys() error {
+	var files []File
+	if err := db.DB.Unscoped().
+		Where("storage_key = '' AND storage_path IS NOT NULL AND storage_path <> ''").
+		Find(&files).Error; err != nil {
+		return err
+	}
+	root := os.Getenv("FILE_STORAGE_PATH")
+	for _, f := range files {
+		rel, err := filepath.Rel(root, f.StoragePath)
+		key := filepath.ToSlash(rel)
+		if err != nil || !storage.ValidKey(key) {
+			log.Printf("file %d: %s is not under FILE_STORAGE_PATH; storage key not set", f.ID, f.StoragePath)
+			continue
+		}
+		if err := db.DB.Unscoped().Model(&File{}).Where("id = ?", f.ID).
+			Update("storage_key", key).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
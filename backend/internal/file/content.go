@@ -0,0 +1,160 @@
+// internal/file/content.go
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/storage"
+)
+
+// GET /buckets/{bucketId}/files/{fileId}/content
+// Streams the stored file with its Content-Type. Range and conditional
+// requests are supported; ?download=1 asks the browser to save it rather
+// than display it.
+func FileContentHandler(w http.ResponseWriter, r *http.Request) {
+	f, ok := loadOwnedFile(w, r)
+	if !ok {
+		return
+	}
+	if f.StorageKey == "" {
+		http.Error(w, "file content not available", http.StatusNotFound)
+		return
+	}
+
+	blob, err := storage.Store.Open(r.Context(), f.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "file content not available", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "could not read file", http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(f.StorageKey))
+	if f.ContentType != nil && *f.ContentType != "" {
+		contentType = *f.ContentType
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	name := f.Filename
+	if f.OriginalFilename != nil && *f.OriginalFilename != "" {
+		name = *f.OriginalFilename
+	}
+	disposition := "inline"
+	if r.URL.Query().Get("download") == "1" {
+		disposition = "attachment"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name}))
+	// Uploaded HTML must not run as part of this site.
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if f.ContentHash != nil {
+		w.Header().Set("ETag", strconv.Quote(*f.ContentHash))
+	}
+	http.ServeContent(w, r, "", f.UpdatedAt, blob)
+}
+
+const (
+	defaultChunkPage = 50
+	maxChunkPage     = 200
+)
+
+type chunkResp struct {
+	Index       int    `json:"index"`
+	Content     string `json:"content"`
+	TokenCount  int    `json:"tokenCount"`
+	HeadingPath string `json:"headingPath,omitempty"`
+	PageStart   *int   `json:"pageStart,omitempty"`
+	PageEnd     *int   `json:"pageEnd,omitempty"`
+	StartMs     *int64 `json:"startMs,omitempty"`
+	EndMs       *int64 `json:"endMs,omitempty"`
+	Embedded    bool   `json:"embedded"`
+}
+
+type chunkPage struct {
+	FileID uint        `json:"fileId"`
+	Status string      `json:"status"`
+	Total  int64       `json:"total"`
+	Offset int         `json:"offset"`
+	Limit  int         `json:"limit"`
+	Chunks []chunkResp `json:"chunks"`
+}
+
+// GET /buckets/{bucketId}/files/{fileId}/chunks?offset=0&limit=50
+// Pages through the text extracted from a file, in document order, so
+// users can check what a quiz was generated from. limit is at most 200.
+func ListChunksHandler(w http.ResponseWriter, r *http.Request) {
+	f, ok := loadOwnedFile(w, r)
+	if !ok {
+		return
+	}
+
+	offset, limit := 0, defaultChunkPage
+	q := r.URL.Query()
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid offset", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxChunkPage)
+	}
+
+	page := chunkPage{FileID: f.ID, Status: f.Status, Offset: offset, Limit: limit, Chunks: []chunkResp{}}
+	if err := db.DB.Model(&FileChunk{}).Where("file_id = ?", f.ID).Count(&page.Total).Error; err != nil {
+		http.Error(w, "could not fetch chunks", http.StatusInternalServerError)
+		return
+	}
+
+	// Select the embedded flag rather than the vectors themselves.
+	var rows []struct {
+		FileChunk
+		Embedded bool
+	}
+	if err := db.DB.Model(&FileChunk{}).
+		Select("chunk_index, content, token_count, heading_path, page_start, page_end, start_ms, end_ms, embedding IS NOT NULL AS embedded").
+		Where("file_id = ?", f.ID).
+		Order("chunk_index ASC").
+		Offset(offset).Limit(limit).
+		Scan(&rows).Error; err != nil {
+		http.Error(w, "could not fetch chunks", http.StatusInternalServerError)
+		return
+	}
+	for _, c := range rows {
+		page.Chunks = append(page.Chunks, chunkResp{
+			Index:       c.ChunkIndex,
+			Content:     c.Content,
+			TokenCount:  c.TokenCount,
+			HeadingPath: c.HeadingPath,
+			PageStart:   c.PageStart,
+			PageEnd:     c.PageEnd,
+			StartMs:     c.StartMs,
+			EndMs:       c.EndMs,
+			Embedded:    c.Embedded,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
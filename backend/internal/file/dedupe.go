@@ -0,0 +1,84 @@
+// internal/file/dedupe.go
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+
+	"gorm.io/gorm"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+)
+
+// hashFile returns the hex SHA-256 of the file at path.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// duplicateOf finds a live file of userID's with the given content hash:
+// preferably one in bucketID (that hasn't failed), otherwise a completed
+// one in another of their buckets whose chunks can be copied. It returns
+// nil if there is neither.
+func duplicateOf(userID, bucketID uint, hash string) (*File, error) {
+	var f File
+	err := db.DB.
+		Joins("JOIN buckets b ON b.id = files.bucket_id AND b.deleted_at IS NULL").
+		Where("b.user_id = ? AND files.content_hash = ?", userID, hash).
+		Where("(files.bucket_id = ? AND files.status <> 'failed') OR files.status = 'completed'", bucketID).
+		Order(gorm.Expr("files.bucket_id = ? DESC, files.id ASC", bucketID)).
+		First(&f).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// copyFile creates dst (which must have BucketID and Filename set) as a
+// completed copy of src: it shares src's stored content and gets copies of
+// its chunks and embeddings, so nothing is re-extracted or re-embedded.
+func copyFile(tx *gorm.DB, src File, dst *File) error {
+	srcID := src.ID
+	dst.StorageKey = src.StorageKey
+	dst.ContentHash = src.ContentHash
+	if dst.SizeBytes == nil {
+		dst.SizeBytes = src.SizeBytes
+	}
+	if dst.ContentType == nil {
+		dst.ContentType = src.ContentType
+	}
+	dst.Status = "completed"
+	dst.CopiedFromID = &srcID
+	dst.ChunkEncoding = src.ChunkEncoding
+	dst.ChunkMaxTokens = src.ChunkMaxTokens
+	dst.ChunkOverlapTokens = src.ChunkOverlapTokens
+	dst.ChunksTotal = src.ChunksTotal
+	dst.ChunksEmbedded = src.ChunksEmbedded
+	if err := tx.Create(dst).Error; err != nil {
+		return err
+	}
+	return tx.Exec(`
+		INSERT INTO file_chunks (
+			file_id, chunk_index, content, token_count, heading_path,
+			start_ms, end_ms, page_start, page_end, embedding, created_at, updated_at
+		)
+		SELECT ?, chunk_index, content, token_count, heading_path,
+			start_ms, end_ms, page_start, page_end, embedding, NOW(), NOW()
+		FROM file_chunks
+		WHERE file_id = ? AND deleted_at IS NULL
+	`, dst.ID, src.ID).Error
+}
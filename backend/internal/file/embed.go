@@ -0,0 +1,210 @@
+// internal/file/embed.go
+package file
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/ai"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+)
+
+// maxEmbedBatchTokens caps the tokens sent in one embeddings request,
+// well under the API's per-request limit.
+const maxEmbedBatchTokens = 100_000
+
+// embedConfig tunes the embedding pipeline. It is read once per worker
+// process: the rate limits are shared by every file being processed.
+type embedConfig struct {
+	BatchSize         int // chunks per request (EMBED_BATCH_SIZE)
+	Concurrency       int // requests in flight per file (EMBED_CONCURRENCY)
+	RequestsPerMinute int // EMBED_REQUESTS_PER_MINUTE
+	TokensPerMinute   int // EMBED_TOKENS_PER_MINUTE
+}
+
+var (
+	embedOnce       sync.Once
+	embedCfg        embedConfig
+	requestLimiter  *rate.Limiter
+	embedTokenLimit *rate.Limiter
+)
+
+// embedSetup returns the embedding settings and the process-wide token
+// bucket limiters for requests and tokens.
+func embedSetup() (embedConfig, *rate.Limiter, *rate.Limiter) {
+	embedOnce.Do(func() {
+		embedCfg = embedConfig{
+			BatchSize:         envInt("EMBED_BATCH_SIZE", 64, 1, 2048),
+			Concurrency:       envInt("EMBED_CONCURRENCY", 4, 1, 32),
+			RequestsPerMinute: envInt("EMBED_REQUESTS_PER_MINUTE", 500, 1, 100_000),
+			TokensPerMinute:   envInt("EMBED_TOKENS_PER_MINUTE", 1_000_000, 1000, 100_000_000),
+		}
+		requestLimiter = rate.NewLimiter(rate.Limit(float64(embedCfg.RequestsPerMinute)/60), embedCfg.Concurrency)
+		// The burst must fit the largest batch, or WaitN could never succeed.
+		embedTokenLimit = rate.NewLimiter(rate.Limit(float64(embedCfg.TokensPerMinute)/60),
+			max(embedCfg.TokensPerMinute, maxEmbedBatchTokens))
+	})
+	return embedCfg, requestLimiter, embedTokenLimit
+}
+
+// envInt reads a positive integer setting, falling back to def when it is
+// unset or outside [lo, hi].
+func envInt(name string, def, lo, hi int) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || n < lo || n > hi {
+		return def
+	}
+	return n
+}
+
+// embedStats are the throughput figures for embedding one file.
+type embedStats struct {
+	Chunks   int
+	Embedded int
+	Failed   int
+	Requests int
+	Tokens   int
+	Duration time.Duration
+}
+
+func (s embedStats) String() string {
+	secs := s.Duration.Seconds()
+	if secs == 0 {
+		secs = 1e-9
+	}
+	return fmt.Sprintf("%d/%d chunks embedded (%d failed) in %d requests, %d tokens, %s (%.1f chunks/s, %.0f tokens/s)",
+		s.Embedded, s.Chunks, s.Failed, s.Requests, s.Tokens, s.Duration.Round(time.Millisecond),
+		float64(s.Embedded)/secs, float64(s.Tokens)/secs)
+}
+
+// embedBatches groups chunks into requests of at most size chunks and
+// maxEmbedBatchTokens tokens.
+func embedBatches(chunks []FileChunk, size int) [][]FileChunk {
+	var out [][]FileChunk
+	start, tokens := 0, 0
+	for i, c := range chunks {
+		if i > start && (i-start >= size || tokens+c.TokenCount > maxEmbedBatchTokens) {
+			out = append(out, chunks[start:i])
+			start, tokens = i, 0
+		}
+		tokens += c.TokenCount
+	}
+	if start < len(chunks) {
+		out = append(out, chunks[start:])
+	}
+	return out
+}
+
+// embedResult is the outcome of one batch request.
+type embedResult struct {
+	batch   []FileChunk
+	vectors [][]float32
+	err     error
+}
+
+// embedChunks embeds the given (already stored) chunks in batches, with up
+// to Concurrency requests in flight under the shared rate limits, and
+// writes each batch's embeddings with a single UPDATE. A failed batch is
+// logged and counted; the other batches carry on.
+func embedChunks(ctx context.Context, chunks []FileChunk) embedStats {
+	cfg, requests, tokens := embedSetup()
+	stats := embedStats{Chunks: len(chunks)}
+	started := time.Now()
+
+	batches := embedBatches(chunks, cfg.BatchSize)
+	jobs := make(chan []FileChunk)
+	results := make(chan embedResult)
+	var wg sync.WaitGroup
+	for w := 0; w < min(cfg.Concurrency, len(batches)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range jobs {
+				results <- embedBatch(ctx, batch, requests, tokens)
+			}
+		}()
+	}
+	go func() {
+		for _, b := range batches {
+			jobs <- b
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	for res := range results {
+		stats.Requests++
+		if res.err == nil {
+			res.err = saveEmbeddings(res.batch, res.vectors)
+		}
+		if res.err != nil {
+			log.Printf("[ProcessFile] embedding batch of %d chunks (from chunk %d) failed: %v\n",
+				len(res.batch), res.batch[0].ChunkIndex, res.err)
+			stats.Failed += len(res.batch)
+			continue
+		}
+		stats.Embedded += len(res.batch)
+		addEmbedded(res.batch[0].FileID, len(res.batch))
+		for _, c := range res.batch {
+			stats.Tokens += c.TokenCount
+		}
+	}
+	stats.Duration = time.Since(started)
+	return stats
+}
+
+// addEmbedded advances a file's count of embedded chunks, for progress
+// reporting.
+func addEmbedded(fileID uint, n int) {
+	if err := db.DB.Model(&File{}).Where("id = ?", fileID).
+		Update("chunks_embedded", gorm.Expr("COALESCE(chunks_embedded, 0) + ?", n)).Error; err != nil {
+		log.Printf("[ProcessFile] could not record progress: %v\n", err)
+		return
+	}
+	publishFile(fileID)
+}
+
+// embedBatch waits for the rate limiters, then embeds one batch.
+func embedBatch(ctx context.Context, batch []FileChunk, requests, tokens *rate.Limiter) embedResult {
+	n := 0
+	texts := make([]string, len(batch))
+	for i, c := range batch {
+		texts[i] = c.Content
+		n += c.TokenCount
+	}
+	if err := requests.Wait(ctx); err != nil {
+		return embedResult{batch: batch, err: err}
+	}
+	if err := tokens.WaitN(ctx, min(n, tokens.Burst())); err != nil {
+		return embedResult{batch: batch, err: err}
+	}
+	vectors, err := ai.GetEmbeddings(texts)
+	return embedResult{batch: batch, vectors: vectors, err: err}
+}
+
+// saveEmbeddings writes a batch's embeddings in one statement.
+func saveEmbeddings(batch []FileChunk, vectors [][]float32) error {
+	rows := make([]string, len(batch))
+	args := make([]interface{}, 0, 2*len(batch))
+	for i, c := range batch {
+		rows[i] = "(?::bigint, ?::vector)"
+		args = append(args, c.ID, pgvector.NewVector(vectors[i]))
+	}
+	return db.DB.Exec(`
+		UPDATE file_chunks AS fc
+		SET embedding = v.embedding, updated_at = NOW()
+		FROM (VALUES `+strings.Join(rows, ", ")+`) AS v(id, embedding)
+		WHERE fc.id = v.id
+	`, args...).Error
+}
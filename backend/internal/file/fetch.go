@@ -0,0 +1,300 @@
+// internal/file/fetch.go
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html/charset"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/storage"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/utils"
+)
+
+const (
+	// fetchTimeout bounds the whole request, redirects and body included.
+	fetchTimeout = 20 * time.Second
+	// maxFetchBytes caps the size of a fetched page.
+	maxFetchBytes = 10 << 20
+	// maxFetchRedirects is how many redirects a fetch will follow.
+	maxFetchRedirects = 5
+	fetchUserAgent    = "QuizGenie/1.0 (+page import)"
+)
+
+var (
+	ErrInvalidURL      = errors.New("URL must be an absolute http or https URL")
+	ErrBlockedAddress  = errors.New("URL resolves to a blocked address")
+	ErrPageTooLarge    = errors.New("page exceeds the size limit")
+	ErrUnsupportedPage = errors.New("URL did not return an HTML or text page")
+	ErrNoReadableText  = errors.New("page has no readable text")
+)
+
+// blockedPrefixes are address ranges a fetch may never connect to unless
+// listed in URL_FETCH_ALLOWLIST: loopback, private, link-local (including
+// cloud metadata endpoints), carrier-grade NAT, benchmarking, multicast and
+// reserved space.
+var blockedPrefixes = mustPrefixes(
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16",
+	"172.16.0.0/12", "192.0.0.0/24", "192.0.2.0/24", "192.168.0.0/16", "198.18.0.0/15",
+	"198.51.100.0/24", "203.0.113.0/24", "224.0.0.0/4", "240.0.0.0/4",
+	"::/128", "::1/128", "64:ff9b::/96", "100::/64", "2001:db8::/32", "fc00::/7",
+	"fe80::/10", "ff00::/8",
+)
+
+func mustPrefixes(cidrs ...string) []netip.Prefix {
+	out := make([]netip.Prefix, 0, len(cidrs))
+	for _, c := range cidrs {
+		out = append(out, netip.MustParsePrefix(c))
+	}
+	return out
+}
+
+// fetchAllowlist parses URL_FETCH_ALLOWLIST, a comma-separated list of CIDRs
+// or single IPs that may be fetched even though they fall in a blocked range
+// (e.g. an intranet wiki). Malformed entries are ignored.
+func fetchAllowlist() []netip.Prefix {
+	var out []netip.Prefix
+	for _, entry := range strings.Split(os.Getenv("URL_FETCH_ALLOWLIST"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if p, err := netip.ParsePrefix(entry); err == nil {
+			out = append(out, p.Masked())
+		} else if a, err := netip.ParseAddr(entry); err == nil {
+			out = append(out, netip.PrefixFrom(a, a.BitLen()))
+		}
+	}
+	return out
+}
+
+// addressAllowed reports whether a fetch may connect to addr.
+func addressAllowed(addr netip.Addr, allow []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, p := range allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// newFetchClient returns an HTTP client that refuses to connect to blocked
+// addresses. The check runs on the resolved IP of every connection, so it
+// also covers redirects and DNS names that point at internal hosts.
+// Environment proxies are ignored, since they would hide the real target.
+func newFetchClient() *http.Client {
+	allow := fetchAllowlist()
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+			}
+			if !addressAllowed(ap.Addr(), allow) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, ap.Addr())
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: fetchTimeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 15 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrInvalidURL
+			}
+			return nil
+		},
+	}
+}
+
+// fetchedPage is a downloaded page, converted to UTF-8.
+type fetchedPage struct {
+	URL      string // final URL after redirects
+	Title    string
+	Body     []byte
+	HTML     bool   // false for text/plain pages
+	TextHash string // SHA-256 of the readable text, used to detect changes
+}
+
+// parseSourceURL validates a user-supplied URL.
+func parseSourceURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	if u.User != nil {
+		return nil, ErrInvalidURL
+	}
+	u.Fragment = ""
+	return u, nil
+}
+
+// fetchPage downloads rawURL and extracts its readable text.
+func fetchPage(ctx context.Context, rawURL string) (*fetchedPage, error) {
+	u, err := parseSourceURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	req.Header.Set("User-Agent", fetchUserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.8")
+
+	resp, err := newFetchClient().Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlockedAddress) || errors.Is(err, ErrInvalidURL) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("fetch failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch failed: %s", resp.Status)
+	}
+	if resp.ContentLength > maxFetchBytes {
+		return nil, ErrPageTooLarge
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	isHTML := mediaType == "text/html" || mediaType == "application/xhtml+xml"
+	if !isHTML && mediaType != "text/plain" {
+		return nil, ErrUnsupportedPage
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch failed: %w", err)
+	}
+	if len(raw) > maxFetchBytes {
+		return nil, ErrPageTooLarge
+	}
+
+	// Decode using the charset from the header (or <meta>), so the stored
+	// copy is UTF-8 regardless of what the page declared.
+	utf8Reader, err := charset.NewReader(bytes.NewReader(raw), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode page: %w", err)
+	}
+	body, err := io.ReadAll(utf8Reader)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode page: %w", err)
+	}
+
+	page := &fetchedPage{URL: resp.Request.URL.String(), Body: body, HTML: isHTML}
+	var sections []utils.Section
+	if isHTML {
+		doc, err := utils.ParseHTML(io.MultiReader(bytes.NewReader(utf8BOM), bytes.NewReader(body)))
+		if err != nil {
+			return nil, err
+		}
+		page.Title = doc.Title
+		sections = doc.Sections
+	} else if text := strings.TrimSpace(string(body)); text != "" {
+		sections = []utils.Section{{Text: text}}
+	}
+	if len(sections) == 0 {
+		return nil, ErrNoReadableText
+	}
+	page.TextHash = hashSections(sections)
+	return page, nil
+}
+
+// utf8BOM marks stored pages as UTF-8, overriding any <meta charset> left
+// over from the original encoding.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// hashSections fingerprints the readable text of a page, so that changes
+// to ads, scripts or markup alone don't count as a content change.
+func hashSections(sections []utils.Section) string {
+	h := sha256.New()
+	for _, s := range sections {
+		io.WriteString(h, utils.JoinHeadingPath(s.HeadingPath))
+		h.Write([]byte{0})
+		io.WriteString(h, s.Text)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// save stores the page under key (with a BOM for HTML, see utf8BOM),
+// returning the stored size.
+func (p *fetchedPage) save(ctx context.Context, key string) (int64, error) {
+	data := p.Body
+	if p.HTML {
+		data = append(append([]byte{}, utf8BOM...), p.Body...)
+	}
+	return int64(len(data)), storage.Store.Put(ctx, key, bytes.NewReader(data))
+}
+
+// contentType is the media type of the stored page.
+func (p *fetchedPage) contentType() string {
+	if p.HTML {
+		return "text/html; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// displayName is the filename shown for a page: its title, or the URL's
+// host and path when it has none.
+func (p *fetchedPage) displayName() string {
+	name := p.Title
+	if name == "" {
+		if u, err := url.Parse(p.URL); err == nil {
+			name = strings.TrimSuffix(u.Host+u.Path, "/")
+		} else {
+			name = p.URL
+		}
+	}
+	if r := []rune(name); len(r) > 200 {
+		name = string(r[:200])
+	}
+	return name
+}
+
+// sourceStorageName is the on-disk name for a URL source: stable per URL,
+// so a refresh overwrites the previous copy.
+func sourceStorageName(sourceURL string, isHTML bool) string {
+	sum := sha256.Sum256([]byte(sourceURL))
+	ext := ".txt"
+	if isHTML {
+		ext = ".html"
+	}
+	return "url-" + hex.EncodeToString(sum[:8]) + ext
+}
@@ -2,7 +2,10 @@
 package file
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -10,10 +13,14 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
+
+	"gorm.io/gorm"
 
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/auth"
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/bucket"
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/utils"
 
 	"github.com/hibiken/asynq"
 )
@@ -33,30 +40,81 @@ func ensureQueueClient() {
 	queueClient = asynq.NewClient(asynq.RedisClientOpt{Addr: redisAddr})
 }
 
+// processFileMaxRetry is how often a failed ProcessFile task is retried
+// before the file is marked failed.
+const processFileMaxRetry = 5
+
+// enqueueProcessFile queues the ProcessFile task for a file and announces
+// its pending status. It is a no-op (with a warning) when Redis isn't
+// configured.
+func enqueueProcessFile(fileID uint) {
+	publishFile(fileID)
+	// First make sure queueClient is built (after .env is loaded).
+	ensureQueueClient()
+	if queueClient == nil {
+		log.Printf("⚠️  Redis not configured (REDIS_ADDR empty); skipping ProcessFile enqueue")
+		return
+	}
+	payload, err := json.Marshal(map[string]interface{}{"file_id": fileID})
+	if err != nil {
+		log.Printf("failed to marshal ProcessFile payload: %v", err)
+		return
+	}
+	task := asynq.NewTask("ProcessFile", payload, asynq.MaxRetry(processFileMaxRetry))
+	if _, err := queueClient.Enqueue(task); err != nil {
+		log.Printf("failed to enqueue ProcessFile task: %v", err)
+	} else {
+		log.Printf("Enqueued ProcessFile for file_id=%d", fileID)
+	}
+}
+
 type uploadFileResponse struct {
-	FileID uint   `json:"fileId"`
-	Status string `json:"status"`
+	FileID     uint   `json:"fileId"`
+	Status     string `json:"status"`
+	Duplicate  bool   `json:"duplicate,omitempty"`  // the bucket already had this content; FileID is that file
+	CopiedFrom *uint  `json:"copiedFrom,omitempty"` // chunks were copied from this file in another bucket
 }
 
-// POST /buckets/{bucketId}/files
-func UploadFileHandler(w http.ResponseWriter, r *http.Request) {
+// savedUpload is a multipart upload that has been written to storage, or,
+// for a plain zip archive (which is expanded rather than stored), to a
+// local temporary file.
+type savedUpload struct {
+	UserID           uint
+	BucketID         uint
+	Name             string // the "filename" form field, or else OriginalFilename
+	OriginalFilename string // name of the client's file, if it sent one
+	Key              string // storage key; empty for archives
+	Hash             string // hex SHA-256 of the content; empty for archives
+	Size             int64
+	ContentType      string // sniffed, see utils.SniffContentType
+	Archive          string // local path of an uploaded archive; the caller removes it
+	QuotaLeft        int64  // what the user may still store, not counting this upload
+}
+
+// receiveUpload checks the caller owns the bucket in /buckets/{bucketId}/...,
+// and streams the multipart "file" field to storage.Store under a fresh key
+// in the bucket. The upload is limited to MAX_UPLOAD_MB and the user's
+// remaining quota (413), and must sniff as a supported type (415). A
+// "filename" field sent before the file names it. On failure it writes the
+// error response and returns ok=false.
+func receiveUpload(w http.ResponseWriter, r *http.Request) (up savedUpload, ok bool) {
 	// 1) Extract userID from JWT‐injected context
 	claims, ok := auth.FromContext(r.Context())
 	if !ok {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
+		return up, false
 	}
 
-	// 2) Parse bucketId from URL path: "/buckets/{id}/files"
+	// 2) Parse bucketId from URL path: "/buckets/{id}/..."
 	parts := strings.Split(r.URL.Path, "/")
 	if len(parts) < 4 {
 		http.Error(w, "invalid URL", http.StatusBadRequest)
-		return
+		return up, false
 	}
 	bucketID, err := strconv.ParseUint(parts[2], 10, 64)
 	if err != nil {
 		http.Error(w, "invalid bucket ID", http.StatusBadRequest)
-		return
+		return up, false
 	}
 
 	// 3) Verify that this bucket belongs to the current user
@@ -65,85 +123,444 @@ func UploadFileHandler(w http.ResponseWriter, r *http.Request) {
 		Where("id = ? AND user_id = ?", bucketID, claims.UserID).
 		First(&b).Error; err != nil {
 		http.Error(w, "bucket not found", http.StatusNotFound)
-		return
+		return up, false
 	}
 
-	// 4) Parse multipart/form-data (single file field named "file")
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		http.Error(w, "could not parse multipart form", http.StatusBadRequest)
-		return
+	// 4) Work out how much may be uploaded
+	maxUpload, quota := uploadLimits()
+	left, err := quotaLeft(claims.UserID, quota)
+	if err != nil {
+		http.Error(w, "could not check storage quota", http.StatusInternalServerError)
+		return up, false
 	}
-	fileHeader, _, err := r.FormFile("file")
+	up = savedUpload{UserID: claims.UserID, BucketID: uint(bucketID), QuotaLeft: left}
+
+	// 5) Read multipart/form-data as a stream: the "filename" field and the
+	//    "file" itself, without buffering the file in memory or temp files
+	r.Body = http.MaxBytesReader(w, r.Body, maxUpload+multipartSlack)
+	mr, err := r.MultipartReader()
 	if err != nil {
+		http.Error(w, "could not parse multipart form", http.StatusBadRequest)
+		return up, false
+	}
+	saved := false
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			up.discard()
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, sizeError(ErrUploadTooLarge, maxUpload), http.StatusRequestEntityTooLarge)
+			} else {
+				http.Error(w, "could not parse multipart form", http.StatusBadRequest)
+			}
+			return up, false
+		}
+		switch part.FormName() {
+		case "filename":
+			name, _ := io.ReadAll(io.LimitReader(part, 1024))
+			up.Name = string(name)
+		case "file":
+			if saved {
+				continue
+			}
+			if n := filepath.Base(part.FileName()); part.FileName() != "" && n != "." && n != string(filepath.Separator) {
+				up.OriginalFilename = n
+			}
+			if up.Name == "" {
+				up.Name = up.OriginalFilename
+			}
+			if !up.save(w, r, part, maxUpload, quota) {
+				return up, false
+			}
+			saved = true
+		}
+		part.Close()
+	}
+	if !saved {
 		http.Error(w, "could not read uploaded file", http.StatusBadRequest)
-		return
+		return up, false
 	}
-	defer fileHeader.Close()
 
-	// 5) Save the file to disk: FILE_STORAGE_PATH/user_<userID>/bucket_<bucketID>/
-	baseDir := os.Getenv("FILE_STORAGE_PATH")
-	userDir := filepath.Join(baseDir, "user_"+strconv.Itoa(int(claims.UserID)))
-	bucketDir := filepath.Join(userDir, "bucket_"+strconv.FormatUint(bucketID, 10))
-	if err := os.MkdirAll(bucketDir, 0o755); err != nil {
-		http.Error(w, "could not create storage directory", http.StatusInternalServerError)
-		return
+	//    Use the "filename" form field if provided, otherwise the original filename
+	up.Name = cleanFilename(up.Name)
+	return up, true
+}
+
+// cleanFilename reduces a client-supplied name to a plain base name,
+// falling back to "uploaded_file".
+func cleanFilename(name string) string {
+	name = filepath.Base(strings.TrimSpace(name))
+	if name == "." || name == ".." || name == string(filepath.Separator) {
+		return "uploaded_file"
+	}
+	return name
+}
+
+// save streams the file part to storage (or, for an archive, to a temporary
+// file), writing the error response if it can't.
+func (up *savedUpload) save(w http.ResponseWriter, r *http.Request, part io.Reader, maxUpload, quota int64) bool {
+	limit, limitErr := maxUpload, ErrUploadTooLarge
+	if up.QuotaLeft < limit {
+		limit, limitErr = up.QuotaLeft, ErrQuotaExceeded
+	}
+	body := &sizeCap{r: part, limit: limit, err: limitErr}
+	tooLarge := func() bool {
+		if !body.exceeded() {
+			return false
+		}
+		if limitErr == ErrQuotaExceeded {
+			http.Error(w, sizeError(ErrQuotaExceeded, quota), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, sizeError(ErrUploadTooLarge, maxUpload), http.StatusRequestEntityTooLarge)
+		}
+		return true
 	}
 
-	//    Use the "filename" form field if provided, otherwise fallback to original filename
-	origFilename := r.FormValue("filename")
-	if origFilename == "" {
-		origFilename = "uploaded_file"
+	//    Sniff the content; only types the extractors handle are accepted.
+	br := bufio.NewReader(body)
+	head, _ := br.Peek(512)
+	if tooLarge() {
+		return false
 	}
-	dstPath := filepath.Join(bucketDir, filepath.Base(origFilename))
-	outFile, err := os.Create(dstPath)
-	if err != nil {
-		http.Error(w, "could not save file", http.StatusInternalServerError)
-		return
+	contentType, allowed := utils.SniffContentType(head, up.Name)
+	if !allowed {
+		http.Error(w, fmt.Sprintf("unsupported file type %q", contentType), http.StatusUnsupportedMediaType)
+		return false
+	}
+	up.ContentType = contentType
+
+	// 6) A zip container may be an archive to expand, which needs a local
+	//    copy; anything else streams straight to storage.
+	var src io.Reader = br
+	if contentType == "application/zip" {
+		tmp, err := spoolUpload(br, filepath.Ext(up.Name))
+		if tooLarge() {
+			return false
+		}
+		if err != nil {
+			http.Error(w, "error saving file", http.StatusInternalServerError)
+			return false
+		}
+		up.Size = body.n
+		if format, err := utils.DetectFormat(tmp); err == nil && format == utils.FormatZip {
+			up.Archive = tmp
+			return true
+		}
+		defer os.Remove(tmp)
+		f, err := os.Open(tmp)
+		if err != nil {
+			http.Error(w, "error saving file", http.StatusInternalServerError)
+			return false
+		}
+		defer f.Close()
+		src = f
 	}
-	defer outFile.Close()
 
-	if _, err := io.Copy(outFile, fileHeader); err != nil {
+	// 7) Save the file to storage: user_<userID>/bucket_<bucketID>/<uuid>.<ext>
+	key := newBlobKey(up.UserID, up.BucketID, up.Name)
+	hash, err := putHashed(r.Context(), key, src)
+	if tooLarge() {
+		return false
+	}
+	if err != nil {
 		http.Error(w, "error saving file", http.StatusInternalServerError)
-		return
+		return false
 	}
+	up.Key, up.Hash, up.Size = key, hash, body.n
+	return true
+}
 
-	// 6) Create a new File record in the DB with status="pending"
-	f := File{
-		BucketID:    uint(bucketID),
-		Filename:    filepath.Base(dstPath),
-		StoragePath: dstPath,
-		Status:      "pending",
+// discard removes what has been saved of the upload.
+func (up *savedUpload) discard() {
+	if up.Key != "" {
+		deleteBlob(up.Key)
 	}
-	if err := db.DB.Create(&f).Error; err != nil {
-		http.Error(w, "could not insert file record", http.StatusInternalServerError)
+	if up.Archive != "" {
+		os.Remove(up.Archive)
+	}
+}
+
+// applyTo sets the File columns that describe the uploaded content.
+func (up *savedUpload) applyTo(f *File) {
+	f.StorageKey = up.Key
+	f.ContentHash = &up.Hash
+	f.SizeBytes = &up.Size
+	f.ContentType = &up.ContentType
+	if up.OriginalFilename != "" {
+		f.OriginalFilename = &up.OriginalFilename
+	}
+}
+
+// spoolUpload copies r to a local temporary file with the given extension.
+func spoolUpload(r io.Reader, ext string) (string, error) {
+	tmp, err := os.CreateTemp("", "quizgenie-upload-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// POST /buckets/{bucketId}/files
+// A .zip upload is expanded into one file per supported entry (see
+// importArchive). Content the bucket already has returns the existing file
+// (200); content already processed in another of the user's buckets is
+// copied, chunks and embeddings included, without being processed again.
+func UploadFileHandler(w http.ResponseWriter, r *http.Request) {
+	// 1-7) Check access and limits, and save the upload
+	up, ok := receiveUpload(w, r)
+	if !ok {
 		return
 	}
+	createFromUpload(w, r, up)
+}
 
-	// 7) Enqueue background job to process this file.
-	//    First make sure queueClient is built (after .env is loaded).
-	ensureQueueClient()
-	if queueClient != nil {
-		payload, err := json.Marshal(map[string]interface{}{"file_id": f.ID})
+// createFromUpload turns a saved upload into a File (or, for an archive,
+// Files) in its bucket, deduplicating its content, and writes the response.
+// It reports whether the upload now has a File; if not, the error response
+// is written and the stored content discarded.
+func createFromUpload(w http.ResponseWriter, r *http.Request, up savedUpload) bool {
+	//    A plain .zip is expanded into one file per supported entry.
+	if up.Archive != "" {
+		return importArchive(w, r, up, false)
+	}
+
+	// 8) Look for the same content among the user's files
+	dup, err := duplicateOf(up.UserID, up.BucketID, up.Hash)
+	if err != nil {
+		up.discard()
+		http.Error(w, "could not check for duplicates", http.StatusInternalServerError)
+		return false
+	}
+	if dup != nil && dup.BucketID == up.BucketID {
+		up.discard()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(uploadFileResponse{FileID: dup.ID, Status: dup.Status, Duplicate: true})
+		return true
+	}
+
+	// 9) Create a new File record in the DB with status="pending", or a
+	//    completed copy of the identical file from another bucket (which
+	//    shares that file's stored content)
+	f := File{
+		BucketID: up.BucketID,
+		Filename: up.Name,
+		Status:   "pending",
+	}
+	up.applyTo(&f)
+	if dup != nil {
+		err := db.DB.Transaction(func(tx *gorm.DB) error { return copyFile(tx, *dup, &f) })
+		up.discard()
 		if err != nil {
-			log.Printf("failed to marshal ProcessFile payload: %v", err)
-		} else {
-			task := asynq.NewTask("ProcessFile", payload)
-			if _, err := queueClient.Enqueue(task); err != nil {
-				log.Printf("failed to enqueue ProcessFile task: %v", err)
-			} else {
-				log.Printf("Enqueued ProcessFile for file_id=%d", f.ID)
-			}
+			http.Error(w, "could not copy file", http.StatusInternalServerError)
+			return false
 		}
-	} else {
-		log.Printf("⚠️  Redis not configured (REDIS_ADDR empty); skipping ProcessFile enqueue")
+		publishFile(f.ID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(uploadFileResponse{FileID: f.ID, Status: f.Status, CopiedFrom: f.CopiedFromID})
+		return true
+	}
+	if err := db.DB.Create(&f).Error; err != nil {
+		up.discard()
+		http.Error(w, "could not insert file record", http.StatusInternalServerError)
+		return false
 	}
 
+	// 10) Enqueue background job to process this file.
+	enqueueProcessFile(f.ID)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(uploadFileResponse{
 		FileID: f.ID,
 		Status: f.Status,
 	})
+	return true
+}
+
+type archiveFile struct {
+	FileID     uint   `json:"fileId"`
+	Filename   string `json:"filename"`
+	Status     string `json:"status"`
+	CopiedFrom *uint  `json:"copiedFrom,omitempty"`
+}
+
+type archiveUploadResponse struct {
+	Archive  string         `json:"archive"`
+	Accepted []archiveFile  `json:"accepted"`
+	Skipped  []skippedEntry `json:"skipped"`
+	Links    *linkSummary   `json:"links,omitempty"` // vault imports only
+}
+
+// importArchive expands an uploaded zip into a temporary directory, stores
+// each accepted entry (within the user's quota), creates a File per entry,
+// queues them for processing and responds with what was accepted and
+// skipped. For a vault, the links between notes are also recorded (see
+// buildNoteLinks). The archive itself is not kept. Entries whose content
+// the bucket already has (or that repeat an earlier entry) are skipped;
+// ones processed in another of the user's buckets are copied like single
+// uploads. It reports whether the Files were created.
+func importArchive(w http.ResponseWriter, r *http.Request, up savedUpload, vault bool) bool {
+	defer os.Remove(up.Archive)
+
+	archiveName := up.Name
+	tmpDir, err := os.MkdirTemp("", "quizgenie-archive-*")
+	if err != nil {
+		http.Error(w, "could not expand archive", http.StatusInternalServerError)
+		return false
+	}
+	defer os.RemoveAll(tmpDir)
+	entries, skipped, err := expandArchive(up.Archive, tmpDir)
+	if err != nil {
+		if isArchiveError(err) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "could not expand archive", http.StatusBadRequest)
+		}
+		return false
+	}
+
+	resp := archiveUploadResponse{
+		Archive:  archiveName,
+		Accepted: make([]archiveFile, 0, len(entries)),
+		Skipped:  skipped,
+	}
+
+	var (
+		files   = make([]File, 0, len(entries))
+		copies  []File
+		sources []File // sources[i] is what copies[i] is copied from
+		seen    = make(map[string]string)
+		stored  int64 // bytes of new content, counted against the quota
+	)
+	// discard removes what was stored so far when the import fails.
+	discard := func() {
+		for _, f := range files {
+			deleteBlob(f.StorageKey)
+		}
+	}
+	for _, e := range entries {
+		hash, err := hashFile(e.Path)
+		if err != nil {
+			discard()
+			http.Error(w, "could not read archive entry", http.StatusInternalServerError)
+			return false
+		}
+		if first, ok := seen[hash]; ok {
+			resp.Skipped = append(resp.Skipped, skippedEntry{Path: e.Name, Reason: "same content as " + first})
+			continue
+		}
+		seen[hash] = e.Name
+		dup, err := duplicateOf(up.UserID, up.BucketID, hash)
+		if err != nil {
+			discard()
+			http.Error(w, "could not check for duplicates", http.StatusInternalServerError)
+			return false
+		}
+		size, contentType, name := e.Size, e.ContentType, e.Name
+		f := File{
+			BucketID:         up.BucketID,
+			Filename:         e.Name,
+			StorageKey:       newBlobKey(up.UserID, up.BucketID, e.Name),
+			Status:           "pending",
+			ContentHash:      &hash,
+			SizeBytes:        &size,
+			ContentType:      &contentType,
+			OriginalFilename: &name,
+		}
+		switch {
+		case dup == nil:
+			if stored += e.Size; stored > up.QuotaLeft {
+				discard()
+				_, quota := uploadLimits()
+				http.Error(w, sizeError(ErrQuotaExceeded, quota), http.StatusRequestEntityTooLarge)
+				return false
+			}
+			if err := putFile(r.Context(), f.StorageKey, e.Path); err != nil {
+				discard()
+				http.Error(w, "could not store archive entry", http.StatusInternalServerError)
+				return false
+			}
+			files = append(files, f)
+		case dup.BucketID == up.BucketID:
+			resp.Skipped = append(resp.Skipped, skippedEntry{Path: e.Name, Reason: "already in bucket as " + dup.Filename})
+		default:
+			copies = append(copies, f)
+			sources = append(sources, *dup)
+		}
+	}
+	if resp.Skipped == nil {
+		resp.Skipped = []skippedEntry{}
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if len(files) > 0 {
+			if err := tx.Create(&files).Error; err != nil {
+				return err
+			}
+		}
+		for i := range copies {
+			if err := copyFile(tx, sources[i], &copies[i]); err != nil {
+				return err
+			}
+		}
+		if vault && len(files)+len(copies) > 0 {
+			summary, err := buildNoteLinks(tx, up.BucketID, append(append([]File(nil), files...), copies...))
+			if err != nil {
+				return err
+			}
+			resp.Links = &summary
+		}
+		return nil
+	})
+	if err != nil {
+		discard()
+		http.Error(w, "could not insert file records", http.StatusInternalServerError)
+		return false
+	}
+
+	for _, f := range files {
+		enqueueProcessFile(f.ID)
+		resp.Accepted = append(resp.Accepted, archiveFile{FileID: f.ID, Filename: f.Filename, Status: f.Status})
+	}
+	for _, f := range copies {
+		publishFile(f.ID)
+		resp.Accepted = append(resp.Accepted, archiveFile{FileID: f.ID, Filename: f.Filename, Status: f.Status, CopiedFrom: f.CopiedFromID})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+	return true
+}
+
+// POST /buckets/{bucketId}/sources/vault
+// Imports a zipped Markdown vault (Obsidian, or a Notion Markdown export):
+// every supported file becomes a File, and [[wikilinks]] and relative links
+// between notes are stored as NoteLinks.
+func ImportVaultHandler(w http.ResponseWriter, r *http.Request) {
+	up, ok := receiveUpload(w, r)
+	if !ok {
+		return
+	}
+	if up.Archive == "" {
+		up.discard()
+		http.Error(w, "vault must be uploaded as a .zip archive", http.StatusBadRequest)
+		return
+	}
+	importArchive(w, r, up, true)
 }
 
 func ListFilesHandler(w http.ResponseWriter, r *http.Request) {
@@ -179,19 +596,449 @@ func ListFilesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type fileResp struct {
-		ID       uint   `json:"id"`
-		Filename string `json:"filename"`
-		Status   string `json:"status"`
+		ID               uint       `json:"id"`
+		Filename         string     `json:"filename"`
+		Status           string     `json:"status"`
+		SourceURL        *string    `json:"sourceUrl,omitempty"`
+		FetchedAt        *time.Time `json:"fetchedAt,omitempty"`
+		SizeBytes        *int64     `json:"sizeBytes,omitempty"`
+		ContentType      *string    `json:"contentType,omitempty"`
+		OriginalFilename *string    `json:"originalFilename,omitempty"`
+		Stage            *string    `json:"stage,omitempty"`
+		ChunksTotal      *int       `json:"chunksTotal,omitempty"`
+		ChunksEmbedded   *int       `json:"chunksEmbedded,omitempty"`
 	}
 	var out []fileResp
 	for _, f := range files {
 		out = append(out, fileResp{
-			ID:       f.ID,
-			Filename: f.Filename,
-			Status:   f.Status,
+			ID:               f.ID,
+			Filename:         f.Filename,
+			Status:           f.Status,
+			SourceURL:        f.SourceURL,
+			FetchedAt:        f.FetchedAt,
+			SizeBytes:        f.SizeBytes,
+			ContentType:      f.ContentType,
+			OriginalFilename: f.OriginalFilename,
+			Stage:            f.Stage,
+			ChunksTotal:      f.ChunksTotal,
+			ChunksEmbedded:   f.ChunksEmbedded,
 		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(out)
 }
+
+// writeFetchError maps a fetchPage error to an HTTP response.
+func writeFetchError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidURL), errors.Is(err, ErrBlockedAddress):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrPageTooLarge), errors.Is(err, ErrUnsupportedPage), errors.Is(err, ErrNoReadableText):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
+
+type addURLSourceReq struct {
+	URL string `json:"url"`
+}
+
+type urlSourceResponse struct {
+	FileID    uint      `json:"fileId"`
+	Filename  string    `json:"filename"`
+	Status    string    `json:"status"`
+	SourceURL string    `json:"sourceUrl"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	Changed   *bool     `json:"changed,omitempty"`
+}
+
+// POST /buckets/{bucketId}/sources/url
+// Fetches a web page and adds its readable text to the bucket as a file.
+func AddURLSourceHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 5 {
+		http.Error(w, "invalid URL", http.StatusBadRequest)
+		return
+	}
+	bucketID, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid bucket ID", http.StatusBadRequest)
+		return
+	}
+
+	var b bucket.Bucket
+	if err := db.DB.
+		Where("id = ? AND user_id = ?", bucketID, claims.UserID).
+		First(&b).Error; err != nil {
+		http.Error(w, "bucket not found", http.StatusNotFound)
+		return
+	}
+
+	var req addURLSourceReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	u, err := parseSourceURL(req.URL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sourceURL := u.String()
+
+	// One file per URL per bucket; adding it again would clobber the stored copy.
+	var existing File
+	if err := db.DB.Where("bucket_id = ? AND source_url = ?", bucketID, sourceURL).
+		First(&existing).Error; err == nil {
+		http.Error(w, fmt.Sprintf("URL already added as file %d; refresh it instead", existing.ID), http.StatusConflict)
+		return
+	}
+
+	page, err := fetchPage(r.Context(), sourceURL)
+	if err != nil {
+		writeFetchError(w, err)
+		return
+	}
+
+	_, quota := uploadLimits()
+	left, err := quotaLeft(claims.UserID, quota)
+	if err != nil {
+		http.Error(w, "could not check storage quota", http.StatusInternalServerError)
+		return
+	}
+	if int64(len(page.Body)) > left {
+		http.Error(w, sizeError(ErrQuotaExceeded, quota), http.StatusRequestEntityTooLarge)
+		return
+	}
+	key := bucketKey(claims.UserID, uint(bucketID), sourceStorageName(sourceURL, page.HTML))
+	size, err := page.save(r.Context(), key)
+	if err != nil {
+		http.Error(w, "could not save page", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	contentType := page.contentType()
+	f := File{
+		BucketID:    uint(bucketID),
+		Filename:    page.displayName(),
+		StorageKey:  key,
+		Status:      "pending",
+		SourceURL:   &sourceURL,
+		FetchedAt:   &now,
+		SourceHash:  &page.TextHash,
+		SizeBytes:   &size,
+		ContentType: &contentType,
+	}
+	if err := db.DB.Create(&f).Error; err != nil {
+		deleteBlob(key)
+		http.Error(w, "could not insert file record", http.StatusInternalServerError)
+		return
+	}
+	enqueueProcessFile(f.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(urlSourceResponse{
+		FileID:    f.ID,
+		Filename:  f.Filename,
+		Status:    f.Status,
+		SourceURL: sourceURL,
+		FetchedAt: now,
+	})
+}
+
+// POST /buckets/{bucketId}/files/{fileId}/refresh
+// Re-fetches a URL source. If its readable text changed, the stored copy is
+// replaced, the old chunks are removed and the file is processed again.
+func RefreshFileHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	f, ok := loadOwnedFile(w, r)
+	if !ok {
+		return
+	}
+	if f.SourceURL == nil {
+		http.Error(w, "file was not imported from a URL", http.StatusBadRequest)
+		return
+	}
+	if isBusy(f) {
+		http.Error(w, "file is still being processed", http.StatusConflict)
+		return
+	}
+
+	page, err := fetchPage(r.Context(), *f.SourceURL)
+	if err != nil {
+		writeFetchError(w, err)
+		return
+	}
+
+	now := time.Now()
+	changed := f.SourceHash == nil || *f.SourceHash != page.TextHash
+	if !changed {
+		if err := db.DB.Model(&f).Update("fetched_at", now).Error; err != nil {
+			http.Error(w, "could not update file", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		// The new copy overwrites the old one, so only the growth counts.
+		var oldSize int64
+		if f.SizeBytes != nil {
+			oldSize = *f.SizeBytes
+		}
+		_, quota := uploadLimits()
+		left, err := quotaLeftExcluding(claims.UserID, quota, oldSize)
+		if err != nil {
+			http.Error(w, "could not check storage quota", http.StatusInternalServerError)
+			return
+		}
+		if int64(len(page.Body)) > left {
+			http.Error(w, sizeError(ErrQuotaExceeded, quota), http.StatusRequestEntityTooLarge)
+			return
+		}
+		size, err := page.save(r.Context(), f.StorageKey)
+		if err != nil {
+			http.Error(w, "could not save page", http.StatusInternalServerError)
+			return
+		}
+		err = db.DB.Transaction(func(tx *gorm.DB) error {
+			if err := removeChunks(tx, f.ID); err != nil {
+				return err
+			}
+			return tx.Model(&f).Updates(map[string]interface{}{
+				"filename":        page.displayName(),
+				"status":          "pending",
+				"error_msg":       nil,
+				"stage":           nil,
+				"chunks_total":    nil,
+				"chunks_embedded": nil,
+				"fetched_at":      now,
+				"source_hash":     page.TextHash,
+				"size_bytes":      size,
+				"content_type":    page.contentType(),
+			}).Error
+		})
+		if err != nil {
+			http.Error(w, "could not update file", http.StatusInternalServerError)
+			return
+		}
+		f.Filename, f.Status = page.displayName(), "pending"
+		enqueueProcessFile(f.ID)
+	}
+
+	status := http.StatusOK
+	if changed {
+		status = http.StatusAccepted
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(urlSourceResponse{
+		FileID:    f.ID,
+		Filename:  f.Filename,
+		Status:    f.Status,
+		SourceURL: *f.SourceURL,
+		FetchedAt: now,
+		Changed:   &changed,
+	})
+}
+
+// loadOwnedFile resolves /buckets/{bucketId}/files/{fileId}/... to a live
+// file in a bucket the caller owns. On failure it writes the error response
+// and returns ok=false.
+func loadOwnedFile(w http.ResponseWriter, r *http.Request) (f File, ok bool) {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return f, false
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 5 {
+		http.Error(w, "invalid URL", http.StatusBadRequest)
+		return f, false
+	}
+	bucketID, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid bucket ID", http.StatusBadRequest)
+		return f, false
+	}
+	fileID, err := strconv.ParseUint(parts[4], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid file ID", http.StatusBadRequest)
+		return f, false
+	}
+
+	var b bucket.Bucket
+	if err := db.DB.
+		Where("id = ? AND user_id = ?", bucketID, claims.UserID).
+		First(&b).Error; err != nil {
+		http.Error(w, "bucket not found", http.StatusNotFound)
+		return f, false
+	}
+
+	if err := db.DB.Where("id = ? AND bucket_id = ?", fileID, bucketID).First(&f).Error; err != nil {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return f, false
+	}
+	return f, true
+}
+
+// isBusy reports whether a worker is processing a file, when its content
+// and chunks must not be changed under it. A pending file is not busy: its
+// task may never have been queued (e.g. Redis was down), and a queued task
+// for a file deleted or changed meanwhile just works on what is there then.
+func isBusy(f File) bool {
+	return f.Status == "processing"
+}
+
+// removeChunks hard-deletes a file's chunks and its note links. Questions
+// generated from the chunks keep working; their citations are marked as
+// removed (see quiz.loadCitations).
+func removeChunks(tx *gorm.DB, fileID uint) error {
+	if err := tx.Unscoped().Where("file_id = ?", fileID).Delete(&FileChunk{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("from_file_id = ? OR to_file_id = ?", fileID, fileID).Delete(&NoteLink{}).Error
+}
+
+// DELETE /buckets/{bucketId}/files/{fileId}
+// Soft-deletes the file, removes its chunks and note links, and deletes
+// the stored content unless another file shares it.
+func DeleteFileHandler(w http.ResponseWriter, r *http.Request) {
+	f, ok := loadOwnedFile(w, r)
+	if !ok {
+		return
+	}
+	if isBusy(f) {
+		http.Error(w, "file is still being processed", http.StatusConflict)
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := removeChunks(tx, f.ID); err != nil {
+			return err
+		}
+		return tx.Delete(&f).Error
+	})
+	if err != nil {
+		http.Error(w, "could not delete file", http.StatusInternalServerError)
+		return
+	}
+	removeBlobIfUnused(f.StorageKey)
+	publishFile(f.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /buckets/{bucketId}/files/{fileId}
+// Replaces the file's content with a new multipart "file" upload and
+// reprocesses it. The old chunks are removed; identical content is a no-op.
+func ReplaceFileHandler(w http.ResponseWriter, r *http.Request) {
+	f, ok := loadOwnedFile(w, r)
+	if !ok {
+		return
+	}
+	if isBusy(f) {
+		http.Error(w, "file is still being processed", http.StatusConflict)
+		return
+	}
+	up, ok := receiveUpload(w, r)
+	if !ok {
+		return
+	}
+	if up.Archive != "" {
+		up.discard()
+		http.Error(w, "a file cannot be replaced with an archive", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if f.ContentHash != nil && *f.ContentHash == up.Hash {
+		up.discard()
+		json.NewEncoder(w).Encode(uploadFileResponse{FileID: f.ID, Status: f.Status, Duplicate: true})
+		return
+	}
+
+	oldKey := f.StorageKey
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := removeChunks(tx, f.ID); err != nil {
+			return err
+		}
+		return tx.Model(&f).Updates(map[string]interface{}{
+			"filename":          up.Name,
+			"storage_key":       up.Key,
+			"content_hash":      up.Hash,
+			"size_bytes":        up.Size,
+			"content_type":      up.ContentType,
+			"original_filename": nullIfEmpty(up.OriginalFilename),
+			"status":            "pending",
+			"error_msg":         nil,
+			"stage":             nil,
+			"chunks_total":      nil,
+			"chunks_embedded":   nil,
+			"source_url":        nil,
+			"fetched_at":        nil,
+			"source_hash":       nil,
+			"copied_from_id":    nil,
+		}).Error
+	})
+	if err != nil {
+		up.discard()
+		http.Error(w, "could not update file", http.StatusInternalServerError)
+		return
+	}
+	removeBlobIfUnused(oldKey)
+	enqueueProcessFile(f.ID)
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(uploadFileResponse{FileID: f.ID, Status: "pending"})
+}
+
+// POST /buckets/{bucketId}/files/{fileId}/reprocess
+// Re-runs extraction and chunking with the current settings and re-embeds
+// every chunk with the current model. Chunks whose text is unchanged keep
+// their IDs, so citations of them still resolve; citations of chunks whose
+// text changed resolve as removed.
+func ReprocessFileHandler(w http.ResponseWriter, r *http.Request) {
+	f, ok := loadOwnedFile(w, r)
+	if !ok {
+		return
+	}
+	if isBusy(f) {
+		http.Error(w, "file is still being processed", http.StatusConflict)
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&FileChunk{}).Where("file_id = ?", f.ID).Update("embedding", nil).Error; err != nil {
+			return err
+		}
+		return tx.Model(&f).Updates(map[string]interface{}{
+			"status":          "pending",
+			"error_msg":       nil,
+			"stage":           nil,
+			"chunks_total":    nil,
+			"chunks_embedded": nil,
+			"copied_from_id":  nil,
+		}).Error
+	})
+	if err != nil {
+		http.Error(w, "could not update file", http.StatusInternalServerError)
+		return
+	}
+	enqueueProcessFile(f.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(uploadFileResponse{FileID: f.ID, Status: "pending"})
+}
@@ -11,9 +11,48 @@ type File struct {
 	ID          uint           `gorm:"primaryKey"`
 	BucketID    uint           `gorm:"index;not null"`
 	Filename    string         `gorm:"size:255;not null"`
-	StoragePath string         `gorm:"size:500;not null"`
+	StorageKey  string         `gorm:"size:500;not null;default:'';index"` // where the content is kept in storage.Store
+	StoragePath string         `gorm:"size:500"`                // Deprecated: local path of files stored before StorageKey
 	Status      string         `gorm:"size:20;not null"`        // "pending", "processing", "completed", "failed"
 	ErrorMsg    *string        `gorm:"type:text"`               // nullable if no error
+
+	// While processing: the current step ("extracting", "chunking",
+	// "embedding" or "naming") and how many of the file's chunks have been
+	// embedded so far. Stage is cleared on completion and kept on failure,
+	// to show where it failed.
+	Stage          *string `gorm:"size:20"`
+	ChunksTotal    *int
+	ChunksEmbedded *int
+
+	// Set for pages imported by URL: where it came from, when it was last
+	// fetched and a hash of its readable text (to skip unchanged refreshes).
+	SourceURL   *string        `gorm:"size:2048"`
+	FetchedAt   *time.Time
+	SourceHash  *string        `gorm:"size:64"`
+
+	// ContentHash is the hex SHA-256 of the stored content, used to spot
+	// re-uploads. CopiedFromID is set when the file's chunks were copied
+	// from an identical file in another bucket instead of being processed.
+	ContentHash  *string `gorm:"size:64;index"`
+	CopiedFromID *uint
+
+	// What was uploaded: its size, sniffed media type and the name of the
+	// client's file (Filename may come from the "filename" form field).
+	SizeBytes        *int64
+	ContentType      *string `gorm:"size:255"`
+	OriginalFilename *string `gorm:"size:255"`
+
+	// How the file was last chunked, so chunkings can be compared; NULL for
+	// files chunked before token-based chunking.
+	ChunkEncoding      *string `gorm:"size:50"`
+	ChunkMaxTokens     *int
+	ChunkOverlapTokens *int
+
+	// Throughput of the last embedding run, for tuning the EMBED_* settings.
+	EmbedRequests      *int
+	EmbedTokens        *int
+	EmbedMs            *int64
+
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	DeletedAt   gorm.DeletedAt `gorm:"index"`
@@ -22,9 +61,24 @@ type File struct {
 // FileChunk represents one chunk of text plus its embedding.
 type FileChunk struct {
 	ID          uint             `gorm:"primaryKey"`
-	FileID      uint             `gorm:"index;not null"`
-	ChunkIndex  int              `gorm:"not null"`
+	// (file_id, chunk_index) is unique among live rows, so reprocessing a
+	// file upserts its chunks instead of duplicating them.
+	FileID      uint             `gorm:"index;uniqueIndex:idx_file_chunks_file_chunk,where:deleted_at IS NULL;not null"`
+	ChunkIndex  int              `gorm:"uniqueIndex:idx_file_chunks_file_chunk,where:deleted_at IS NULL;not null"`
 	Content     string           `gorm:"type:text;not null"`
+	TokenCount  int              `gorm:"not null;default:0"` // in File.ChunkEncoding
+
+	// HeadingPath is where the chunk sits in the document's structure,
+	// e.g. "Chapter 3 > Cell Division" ("" for unstructured text).
+	HeadingPath string           `gorm:"type:text;not null;default:''"`
+
+	// StartMs/EndMs locate transcript chunks in the recording (NULL otherwise).
+	StartMs     *int64
+	EndMs       *int64
+
+	// PageStart/PageEnd are the PDF pages the chunk came from (NULL otherwise).
+	PageStart   *int
+	PageEnd     *int
 
 	// Make Embedding a *pgvector.Vector so that a nil pointer
 	// becomes SQL NULL on INSERT.  We’ll fill it later.
@@ -34,3 +88,37 @@ type FileChunk struct {
 	UpdatedAt   time.Time
 	DeletedAt   gorm.DeletedAt   `gorm:"index"`
 }
+
+// NoteLink is a link from one note to another within a bucket, from a
+// [[wikilink]] or a relative Markdown link in an imported vault.
+type NoteLink struct {
+	ID         uint      `gorm:"primaryKey"`
+	BucketID   uint      `gorm:"index;not null"`
+	FromFileID uint      `gorm:"uniqueIndex:idx_note_links_from_to;not null"`
+	ToFileID   uint      `gorm:"uniqueIndex:idx_note_links_from_to;index;not null"`
+	CreatedAt  time.Time
+}
+
+// UploadSession is a resumable upload in progress: the client declares the
+// file's size up front, then sends it in pieces (see resumable.go).
+type UploadSession struct {
+	ID        string    `gorm:"primaryKey;size:36"` // random UUID
+	UserID    uint      `gorm:"index;not null"`
+	BucketID  uint      `gorm:"not null"`
+	Filename  string    `gorm:"size:255;not null"`
+	Size      int64     `gorm:"not null"`           // declared total length
+	Received  int64     `gorm:"not null;default:0"` // bytes stored so far
+	Status    string    `gorm:"size:20;not null;default:'open'"` // "open", or "completing" while being assembled
+	ExpiresAt time.Time `gorm:"index;not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// UploadPart is one stored piece of an upload session's content.
+type UploadPart struct {
+	ID         uint   `gorm:"primaryKey"`
+	SessionID  string `gorm:"size:36;index;not null"`
+	Start      int64  `gorm:"not null"` // offset of its first byte in the file
+	Size       int64  `gorm:"not null"`
+	StorageKey string `gorm:"size:500;not null"`
+}
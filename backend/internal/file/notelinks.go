@@ -0,0 +1,210 @@
+// internal/file/notelinks.go
+package file
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/storage"
+)
+
+// linkSummary reports how many links a vault import could resolve.
+type linkSummary struct {
+	Resolved   int      `json:"resolved"`
+	Unresolved int      `json:"unresolved"`
+	Missing    []string `json:"missing,omitempty"` // distinct unresolved targets
+}
+
+var (
+	// wikiLink matches [[Note]], [[folder/Note|alias]], [[Note#Heading]] and
+	// embeds like ![[Note]]; group 1 is the target.
+	wikiLink = regexp.MustCompile(`\[\[([^\]|#^]*)(?:[#^][^\]|]*)?(?:\|[^\]]*)?\]\]`)
+	// mdLink matches [text](target) and [text](<target with spaces> "title").
+	mdLink = regexp.MustCompile(`\[[^\]]*\]\(\s*(<[^>]*>|[^)\s]+)(?:\s+"[^"]*")?\s*\)`)
+	// fencedCode matches ``` / ~~~ blocks, whose contents aren't links.
+	fencedCode = regexp.MustCompile("(?ms)^ {0,3}(```|~~~).*?^ {0,3}(```|~~~)[ \t]*$")
+	inlineCode = regexp.MustCompile("`[^`\n]*`")
+)
+
+// noteExts are the extensions of files treated as notes.
+var noteExts = map[string]bool{".md": true, ".markdown": true}
+
+// linkTarget is a link found in a note, before resolution.
+type linkTarget struct {
+	target string
+	wiki   bool
+}
+
+// parseNoteLinks returns the internal link targets in a note, ignoring
+// code, external URLs and same-page anchors.
+func parseNoteLinks(content string) []linkTarget {
+	content = fencedCode.ReplaceAllString(content, "")
+	content = inlineCode.ReplaceAllString(content, "")
+
+	var out []linkTarget
+	for _, m := range wikiLink.FindAllStringSubmatch(content, -1) {
+		if t := strings.TrimSpace(m[1]); t != "" {
+			out = append(out, linkTarget{target: t, wiki: true})
+		}
+	}
+	for _, m := range mdLink.FindAllStringSubmatch(content, -1) {
+		t := strings.TrimSuffix(strings.TrimPrefix(m[1], "<"), ">")
+		if i := strings.IndexByte(t, '#'); i >= 0 {
+			t = t[:i]
+		}
+		if t == "" || strings.Contains(t, "://") || strings.HasPrefix(t, "mailto:") {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(t); err == nil {
+			t = unescaped
+		}
+		out = append(out, linkTarget{target: t})
+	}
+	return out
+}
+
+// noteResolver finds the file a link points at among a vault's files,
+// matching case-insensitively the way Obsidian does.
+type noteResolver struct {
+	byPath map[string]uint     // lower-cased relative path → file ID
+	byName map[string][]string // lower-cased base name without extension → paths
+}
+
+func newNoteResolver(files []File) *noteResolver {
+	r := &noteResolver{byPath: make(map[string]uint), byName: make(map[string][]string)}
+	for _, f := range files {
+		p := strings.ToLower(f.Filename)
+		r.byPath[p] = f.ID
+		base := path.Base(p)
+		if noteExts[path.Ext(base)] {
+			base = strings.TrimSuffix(base, path.Ext(base))
+		}
+		r.byName[base] = append(r.byName[base], p)
+	}
+	for _, paths := range r.byName {
+		sort.Slice(paths, func(i, j int) bool {
+			if len(paths[i]) != len(paths[j]) {
+				return len(paths[i]) < len(paths[j])
+			}
+			return paths[i] < paths[j]
+		})
+	}
+	return r
+}
+
+// resolve returns the file ID a link from the note at fromPath points at.
+func (r *noteResolver) resolve(fromPath string, l linkTarget) (uint, bool) {
+	target := strings.ToLower(strings.TrimPrefix(l.target, "/"))
+	dir := path.Dir(strings.ToLower(fromPath))
+
+	// Relative Markdown links (and Notion's exports) are relative to the note.
+	candidates := []string{path.Join(dir, target), path.Clean(target)}
+	if path.Ext(target) == "" {
+		for _, c := range candidates[:2] {
+			candidates = append(candidates, c+".md")
+		}
+	}
+	for _, c := range candidates {
+		if id, ok := r.byPath[c]; ok {
+			return id, true
+		}
+	}
+	if !l.wiki {
+		return 0, false
+	}
+
+	// Wikilinks name a note anywhere in the vault: by name, or by a path
+	// suffix when it includes folders. Prefer the note's own folder, then
+	// the shortest path.
+	name := path.Base(target)
+	if noteExts[path.Ext(name)] {
+		name = strings.TrimSuffix(name, path.Ext(name))
+	}
+	var best string
+	for _, p := range r.byName[name] {
+		if strings.Contains(target, "/") && !pathHasSuffix(p, target) {
+			continue
+		}
+		if path.Dir(p) == dir {
+			best = p
+			break
+		}
+		if best == "" {
+			best = p
+		}
+	}
+	if best == "" {
+		return 0, false
+	}
+	return r.byPath[best], true
+}
+
+// pathHasSuffix reports whether p ends with the path suffix (with or
+// without a note extension), on a folder boundary.
+func pathHasSuffix(p, suffix string) bool {
+	trimmed := strings.TrimSuffix(p, path.Ext(p))
+	for _, s := range []string{p, trimmed} {
+		if s == suffix || strings.HasSuffix(s, "/"+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildNoteLinks parses every note among files and stores the links it can
+// resolve to other files of the same import. Self-links and repeats are
+// dropped.
+func buildNoteLinks(tx *gorm.DB, bucketID uint, files []File) (linkSummary, error) {
+	resolver := newNoteResolver(files)
+	var (
+		summary linkSummary
+		links   []NoteLink
+		missing = make(map[string]bool)
+		seen    = make(map[[2]uint]bool)
+	)
+	for _, f := range files {
+		if !noteExts[strings.ToLower(path.Ext(f.Filename))] {
+			continue
+		}
+		content, err := storage.ReadAll(context.Background(), storage.Store, f.StorageKey)
+		if err != nil {
+			return summary, err
+		}
+		for _, l := range parseNoteLinks(string(content)) {
+			to, ok := resolver.resolve(f.Filename, l)
+			if !ok {
+				// Attachments (images, etc.) aren't imported, so aren't missing notes.
+				if ext := strings.ToLower(path.Ext(l.target)); ext != "" && !noteExts[ext] {
+					continue
+				}
+				summary.Unresolved++
+				missing[l.target] = true
+				continue
+			}
+			summary.Resolved++
+			key := [2]uint{f.ID, to}
+			if to == f.ID || seen[key] {
+				continue
+			}
+			seen[key] = true
+			links = append(links, NoteLink{BucketID: bucketID, FromFileID: f.ID, ToFileID: to})
+		}
+	}
+	for t := range missing {
+		summary.Missing = append(summary.Missing, t)
+	}
+	sort.Strings(summary.Missing)
+
+	if len(links) == 0 {
+		return summary, nil
+	}
+	err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&links, 500).Error
+	return summary, err
+}
@@ -0,0 +1,513 @@
+// internal/file/resumable.go
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/auth"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/bucket"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/storage"
+)
+
+// Resumable uploads follow the shape of the tus protocol: create a session
+// with the file's size, PATCH the bytes in order with an Upload-Offset
+// header, ask for the offset after a dropped connection and carry on from
+// there, then complete the session to turn it into a File. Each PATCH is
+// stored as its own part in storage.Store, so any API instance can take
+// the next one; a PATCH cut off midway is discarded and must be resent.
+
+// Upload session statuses. A session is "completing" while its parts are
+// being assembled into a File; it goes back to "open" if that fails, so
+// the client can retry without sending the bytes again.
+const (
+	sessionOpen       = "open"
+	sessionCompleting = "completing"
+)
+
+var (
+	// errPartTooLarge is returned when a PATCH runs past the declared size.
+	errPartTooLarge = errors.New("upload is larger than its declared size")
+	// errOffsetMismatch is returned for a PATCH at the wrong offset.
+	errOffsetMismatch = errors.New("Upload-Offset does not match the bytes received")
+	// errSessionCompleting is returned for a change to a session that is
+	// being completed.
+	errSessionCompleting = errors.New("upload is being completed")
+	// errOffsetMoved means another PATCH advanced the session meanwhile.
+	errOffsetMoved = errors.New("upload offset moved")
+)
+
+// uploadSessionTTL is how long an upload session lives after its last
+// PATCH (UPLOAD_SESSION_TTL_HOURS, default 24).
+func uploadSessionTTL() time.Duration {
+	return time.Duration(envInt("UPLOAD_SESSION_TTL_HOURS", 24, 1, 24*30)) * time.Hour
+}
+
+type createUploadReq struct {
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+}
+
+type uploadSessionResponse struct {
+	UploadID  string    `json:"uploadId"`
+	Filename  string    `json:"filename"`
+	Size      int64     `json:"size"`
+	Offset    int64     `json:"offset"`
+	Status    string    `json:"status"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func sessionResponse(s UploadSession) uploadSessionResponse {
+	return uploadSessionResponse{
+		UploadID:  s.ID,
+		Filename:  s.Filename,
+		Size:      s.Size,
+		Offset:    s.Received,
+		Status:    s.Status,
+		ExpiresAt: s.ExpiresAt,
+	}
+}
+
+// POST /buckets/{bucketId}/uploads
+// Starts a resumable upload of {"filename": ..., "size": ...}. The size is
+// checked against MAX_UPLOAD_MB and the user's quota up front, and stays
+// reserved against the quota until the session is completed or expires.
+func CreateUploadHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 4 {
+		http.Error(w, "invalid URL", http.StatusBadRequest)
+		return
+	}
+	bucketID, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid bucket ID", http.StatusBadRequest)
+		return
+	}
+
+	var b bucket.Bucket
+	if err := db.DB.
+		Where("id = ? AND user_id = ?", bucketID, claims.UserID).
+		First(&b).Error; err != nil {
+		http.Error(w, "bucket not found", http.StatusNotFound)
+		return
+	}
+
+	var req createUploadReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Size <= 0 {
+		http.Error(w, "size must be positive", http.StatusBadRequest)
+		return
+	}
+	maxUpload, quota := uploadLimits()
+	if req.Size > maxUpload {
+		http.Error(w, sizeError(ErrUploadTooLarge, maxUpload), http.StatusRequestEntityTooLarge)
+		return
+	}
+	left, err := quotaLeft(claims.UserID, quota)
+	if err != nil {
+		http.Error(w, "could not check storage quota", http.StatusInternalServerError)
+		return
+	}
+	if req.Size > left {
+		http.Error(w, sizeError(ErrQuotaExceeded, quota), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	s := UploadSession{
+		ID:        uuid.NewString(),
+		UserID:    claims.UserID,
+		BucketID:  uint(bucketID),
+		Filename:  cleanFilename(req.Filename),
+		Size:      req.Size,
+		Status:    sessionOpen,
+		ExpiresAt: time.Now().Add(uploadSessionTTL()),
+	}
+	if err := db.DB.Create(&s).Error; err != nil {
+		http.Error(w, "could not create upload session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/buckets/%d/uploads/%s", bucketID, s.ID))
+	w.Header().Set("Upload-Offset", "0")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sessionResponse(s))
+}
+
+// loadOwnedSession resolves /buckets/{bucketId}/uploads/{uploadId}/... to
+// a live upload session of the caller's. On failure it writes the error
+// response and returns ok=false.
+func loadOwnedSession(w http.ResponseWriter, r *http.Request) (s UploadSession, ok bool) {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return s, false
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 5 {
+		http.Error(w, "invalid URL", http.StatusBadRequest)
+		return s, false
+	}
+	bucketID, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid bucket ID", http.StatusBadRequest)
+		return s, false
+	}
+	if err := db.DB.
+		Where("id = ? AND user_id = ? AND bucket_id = ? AND expires_at > ?", parts[4], claims.UserID, bucketID, time.Now()).
+		First(&s).Error; err != nil {
+		http.Error(w, "upload not found or expired", http.StatusNotFound)
+		return s, false
+	}
+	return s, true
+}
+
+// GET (or HEAD) /buckets/{bucketId}/uploads/{uploadId}
+// Reports how much of the upload has been received, as JSON and in the
+// Upload-Offset and Upload-Length headers.
+func GetUploadHandler(w http.ResponseWriter, r *http.Request) {
+	s, ok := loadOwnedSession(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(s.Received, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(s.Size, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessionResponse(s))
+}
+
+// PATCH /buckets/{bucketId}/uploads/{uploadId}
+// Appends the request body at the Upload-Offset header, which must equal
+// the bytes received so far (409 with the current offset otherwise).
+// Responds 204 with the new Upload-Offset.
+func PatchUploadHandler(w http.ResponseWriter, r *http.Request) {
+	s, ok := loadOwnedSession(w, r)
+	if !ok {
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "missing or invalid Upload-Offset header", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(s.Received, 10))
+	if err := checkPatch(s, offset); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	body := &sizeCap{r: r.Body, limit: s.Size - s.Received, err: errPartTooLarge}
+	key := path.Join("uploads", s.ID, uuid.NewString())
+	if err := storage.Store.Put(r.Context(), key, body); err != nil {
+		if body.exceeded() {
+			http.Error(w, errPartTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "could not store upload part", http.StatusInternalServerError)
+		}
+		return
+	}
+	if body.n == 0 {
+		deleteBlob(key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// Advance the offset only if no other PATCH got there first.
+	received := s.Received + body.n
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&UploadSession{}).
+			Where("id = ? AND received = ? AND status = ?", s.ID, s.Received, sessionOpen).
+			Updates(map[string]interface{}{
+				"received":   received,
+				"expires_at": time.Now().Add(uploadSessionTTL()),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errOffsetMoved
+		}
+		return tx.Create(&UploadPart{SessionID: s.ID, Start: s.Received, Size: body.n, StorageKey: key}).Error
+	})
+	if err != nil {
+		deleteBlob(key)
+		if errors.Is(err, errOffsetMoved) {
+			http.Error(w, "upload was written concurrently; query its offset and retry", http.StatusConflict)
+		} else {
+			http.Error(w, "could not record upload part", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(received, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkPatch says why a PATCH at offset can't be applied to s, or returns
+// nil if it can.
+func checkPatch(s UploadSession, offset int64) error {
+	if s.Status != sessionOpen {
+		return errSessionCompleting
+	}
+	if offset != s.Received {
+		return errOffsetMismatch
+	}
+	return nil
+}
+
+// checkParts verifies that parts, ordered by Start, cover exactly the
+// first size bytes without gaps or overlaps.
+func checkParts(parts []UploadPart, size int64) error {
+	var next int64
+	for _, p := range parts {
+		if p.Start != next || p.Size <= 0 {
+			return fmt.Errorf("upload part at %d does not follow byte %d", p.Start, next)
+		}
+		next += p.Size
+	}
+	if next != size {
+		return fmt.Errorf("upload parts cover %d of %d bytes", next, size)
+	}
+	return nil
+}
+
+// POST /buckets/{bucketId}/uploads/{uploadId}/complete
+// Assembles a fully received upload into a File and queues it for
+// processing, exactly like a single-request upload (409 if bytes are
+// still missing or it is already being completed). The session is closed
+// once the File exists; if completing fails, it is reopened and the client
+// may retry or cancel it.
+func CompleteUploadHandler(w http.ResponseWriter, r *http.Request) {
+	s, ok := loadOwnedSession(w, r)
+	if !ok {
+		return
+	}
+	if s.Received != s.Size {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(s.Received, 10))
+		http.Error(w, fmt.Sprintf("upload incomplete: %d of %d bytes received", s.Received, s.Size), http.StatusConflict)
+		return
+	}
+
+	// Claim the session, so a concurrent complete can't assemble it twice.
+	claimed, err := claimSession(s.ID)
+	if err != nil {
+		http.Error(w, "could not complete upload", http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		http.Error(w, errSessionCompleting.Error(), http.StatusConflict)
+		return
+	}
+	created := false
+	defer func() {
+		if !created {
+			reopenSession(s.ID)
+		}
+	}()
+
+	var parts []UploadPart
+	if err := db.DB.Where("session_id = ?", s.ID).Order("start ASC").Find(&parts).Error; err != nil {
+		http.Error(w, "could not complete upload", http.StatusInternalServerError)
+		return
+	}
+	if err := checkParts(parts, s.Size); err != nil {
+		log.Printf("upload session %s: %v", s.ID, err)
+		http.Error(w, "could not complete upload", http.StatusInternalServerError)
+		return
+	}
+
+	// The session's reservation is counted as used; its content takes its
+	// place.
+	maxUpload, quota := uploadLimits()
+	left, err := quotaLeftExcluding(s.UserID, quota, s.Size)
+	if err != nil {
+		http.Error(w, "could not check storage quota", http.StatusInternalServerError)
+		return
+	}
+	up := savedUpload{
+		UserID:           s.UserID,
+		BucketID:         s.BucketID,
+		Name:             s.Filename,
+		OriginalFilename: s.Filename,
+		QuotaLeft:        left,
+	}
+	content := &partsReader{ctx: r.Context(), parts: parts}
+	defer content.Close()
+	if !up.save(w, r, content, maxUpload, quota) {
+		return
+	}
+	if !createFromUpload(w, r, up) {
+		return
+	}
+	created = true
+
+	if _, err := closeSession(s.ID, false); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("could not close upload session %s: %v", s.ID, err)
+		return // the expiry job removes it and its parts later
+	}
+	deleteParts(parts)
+}
+
+// DELETE /buckets/{bucketId}/uploads/{uploadId}
+// Abandons an upload and removes what was received (409 while it is being
+// completed).
+func CancelUploadHandler(w http.ResponseWriter, r *http.Request) {
+	s, ok := loadOwnedSession(w, r)
+	if !ok {
+		return
+	}
+	claimed, err := claimSession(s.ID)
+	if err != nil {
+		http.Error(w, "could not cancel upload", http.StatusInternalServerError)
+		return
+	}
+	if !claimed {
+		http.Error(w, errSessionCompleting.Error(), http.StatusConflict)
+		return
+	}
+	parts, err := closeSession(s.ID, false)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		reopenSession(s.ID)
+		http.Error(w, "could not cancel upload", http.StatusInternalServerError)
+		return
+	}
+	deleteParts(parts)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// claimSession moves an open session to "completing" (giving it a fresh
+// expiry, so it can't expire midway), reporting whether this caller got
+// it.
+func claimSession(id string) (bool, error) {
+	res := db.DB.Model(&UploadSession{}).
+		Where("id = ? AND status = ?", id, sessionOpen).
+		Updates(map[string]interface{}{
+			"status":     sessionCompleting,
+			"expires_at": time.Now().Add(uploadSessionTTL()),
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+// reopenSession returns a claimed session to "open" after a failure.
+func reopenSession(id string) {
+	if err := db.DB.Model(&UploadSession{}).
+		Where("id = ? AND status = ?", id, sessionCompleting).
+		Update("status", sessionOpen).Error; err != nil {
+		log.Printf("could not reopen upload session %s: %v", id, err)
+	}
+}
+
+// closeSession deletes an upload session and its part records, returning
+// the parts in order. With expiredOnly, a session whose expiry has since
+// been extended is left alone. It returns gorm.ErrRecordNotFound if there
+// was no such session.
+func closeSession(id string, expiredOnly bool) (parts []UploadPart, err error) {
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		q := tx.Where("id = ?", id)
+		if expiredOnly {
+			q = q.Where("expires_at <= ?", time.Now())
+		}
+		res := q.Delete(&UploadSession{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Where("session_id = ?", id).Order("start ASC").Find(&parts).Error; err != nil {
+			return err
+		}
+		return tx.Where("session_id = ?", id).Delete(&UploadPart{}).Error
+	})
+	return parts, err
+}
+
+// deleteParts removes the stored content of upload parts.
+func deleteParts(parts []UploadPart) {
+	for _, p := range parts {
+		deleteBlob(p.StorageKey)
+	}
+}
+
+// partsReader reads upload parts back to back, opening each in turn.
+type partsReader struct {
+	ctx   context.Context
+	parts []UploadPart
+	cur   storage.Blob
+}
+
+func (p *partsReader) Read(b []byte) (int, error) {
+	for {
+		if p.cur == nil {
+			if len(p.parts) == 0 {
+				return 0, io.EOF
+			}
+			blob, err := storage.Store.Open(p.ctx, p.parts[0].StorageKey)
+			if err != nil {
+				return 0, err
+			}
+			p.cur, p.parts = blob, p.parts[1:]
+		}
+		n, err := p.cur.Read(b)
+		if err == io.EOF {
+			p.cur.Close()
+			p.cur = nil
+			err = nil
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+func (p *partsReader) Close() error {
+	if p.cur != nil {
+		return p.cur.Close()
+	}
+	return nil
+}
+
+// ExpireUploadSessions removes upload sessions that were left unfinished
+// past their expiry, along with what they received.
+func ExpireUploadSessions() error {
+	var expired []UploadSession
+	if err := db.DB.Where("expires_at <= ?", time.Now()).Find(&expired).Error; err != nil {
+		return err
+	}
+	for _, s := range expired {
+		parts, err := closeSession(s.ID, true)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		deleteParts(parts)
+		log.Printf("expired upload session %s (%d of %d bytes received)", s.ID, s.Received, s.Size)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/storage"
+)
+
+func TestCheckPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		session UploadSession
+		offset  int64
+		want    error
+	}{
+		{"first part", UploadSession{Status: sessionOpen, Size: 10}, 0, nil},
+		{"next part", UploadSession{Status: sessionOpen, Size: 10, Received: 4}, 4, nil},
+		{"resend after a dropped part", UploadSession{Status: sessionOpen, Size: 10, Received: 4}, 7, errOffsetMismatch},
+		{"part already received", UploadSession{Status: sessionOpen, Size: 10, Received: 4}, 0, errOffsetMismatch},
+		{"being completed", UploadSession{Status: sessionCompleting, Size: 10, Received: 10}, 10, errSessionCompleting},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkPatch(tt.session, tt.offset); !errors.Is(err, tt.want) {
+				t.Errorf("checkPatch(offset %d) = %v, want %v", tt.offset, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckParts(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts []UploadPart
+		size  int64
+		ok    bool
+	}{
+		{"single part", []UploadPart{{Start: 0, Size: 10}}, 10, true},
+		{"contiguous parts", []UploadPart{{Start: 0, Size: 4}, {Start: 4, Size: 6}}, 10, true},
+		{"empty upload", nil, 0, true},
+		{"missing tail", []UploadPart{{Start: 0, Size: 4}}, 10, false},
+		{"gap", []UploadPart{{Start: 0, Size: 4}, {Start: 5, Size: 5}}, 10, false},
+		{"overlap", []UploadPart{{Start: 0, Size: 6}, {Start: 4, Size: 6}}, 10, false},
+		{"does not start at zero", []UploadPart{{Start: 2, Size: 8}}, 10, false},
+		{"empty part", []UploadPart{{Start: 0, Size: 0}, {Start: 0, Size: 10}}, 10, false},
+		{"longer than declared", []UploadPart{{Start: 0, Size: 12}}, 10, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkParts(tt.parts, tt.size)
+			if (err == nil) != tt.ok {
+				t.Errorf("checkParts = %v, want ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestQuotaRemaining(t *testing.T) {
+	tests := []struct {
+		name        string
+		quota, used int64
+		want        int64
+	}{
+		{"unlimited", 0, 1 << 40, math.MaxInt64},
+		{"room left", 100, 30, 70},
+		{"exactly full", 100, 100, 0},
+		{"over quota", 100, 130, 0},
+		// A session being completed has its own size subtracted from the
+		// reservations, so it still fits the room it reserved.
+		{"own reservation excluded", 100, 100 - 40, 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quotaRemaining(tt.quota, tt.used); got != tt.want {
+				t.Errorf("quotaRemaining(%d, %d) = %d, want %d", tt.quota, tt.used, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartsReader(t *testing.T) {
+	ctx := context.Background()
+	old := storage.Store
+	storage.Store = storage.NewLocal(t.TempDir())
+	t.Cleanup(func() { storage.Store = old })
+
+	var parts []UploadPart
+	var start int64
+	for i, p := range []string{"hello, ", "resumable ", "world"} {
+		key := fmt.Sprintf("uploads/s/part_%d", i)
+		if err := storage.Store.Put(ctx, key, strings.NewReader(p)); err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, UploadPart{Start: start, Size: int64(len(p)), StorageKey: key})
+		start += int64(len(p))
+	}
+
+	r := &partsReader{ctx: ctx, parts: parts}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "hello, resumable world" {
+		t.Errorf("read %q", got)
+	}
+}
+
+func TestPartsReaderMissingPart(t *testing.T) {
+	old := storage.Store
+	storage.Store = storage.NewLocal(t.TempDir())
+	t.Cleanup(func() { storage.Store = old })
+
+	r := &partsReader{ctx: context.Background(), parts: []UploadPart{{Start: 0, Size: 3, StorageKey: "uploads/s/gone"}}}
+	if _, err := io.ReadAll(r); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("reading a missing part: %v, want ErrNotFound", err)
+	}
+}
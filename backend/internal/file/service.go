@@ -1,101 +1,152 @@
 package file
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"os"
-	"path/filepath"
 	"strings"
 
-	"github.com/pgvector/pgvector-go"
+	"github.com/hibiken/asynq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/ai"
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/bucket"
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/events"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/storage"
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/utils"
 )
 
 // ProcessFile does the full pipeline for a given fileID:
 //  1) mark status="processing"
-//  2) extract text (PDF or plain text)
-//  3) chunk it (~2000 chars each)
-//  4) store each chunk in file_chunks (with NULL embedding), then compute + store embedding
-//  5) once at least one chunk is stored, call AI to generate a bucket name
-//  6) mark file.status="completed" (or "failed" on error)
-func ProcessFile(fileID uint) {
+//  2) extract text (PDF by page, DOCX, PPTX, HTML, EPUB, Markdown, SRT/VTT or plain text) as sections
+//  3) chunk it by tokens (CHUNK_MAX_TOKENS, with CHUNK_OVERLAP_TOKENS of overlap; never across headings)
+//  4) upsert the chunks into file_chunks by (file_id, chunk_index), keeping the embedding of
+//     any chunk whose text is unchanged, then embed only the chunks still missing one, in
+//     batches (EMBED_BATCH_SIZE per request, EMBED_CONCURRENCY in flight, rate-limited)
+//  5) once every chunk is embedded, call AI to generate a bucket name
+//  6) mark file.status="completed"
+//
+// It is safe to run again on the same file, e.g. when asynq retries it after
+// a crash. Errors that a retry can't fix (the file can't be read or has no
+// text) mark the file "failed" and wrap asynq.SkipRetry; other errors are
+// returned as-is for asynq to retry, and the worker marks the file "failed"
+// after the last attempt (see FailFile).
+func ProcessFile(ctx context.Context, fileID uint) error {
 	// 1) Fetch file record
 	var frec File
 	if err := db.DB.First(&frec, fileID).Error; err != nil {
-		log.Printf("[ProcessFile] could not find File ID=%d: %v\n", fileID, err)
-		return
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Deleted since it was queued; nothing left to do.
+			return fmt.Errorf("could not find File ID=%d: %w", fileID, asynq.SkipRetry)
+		}
+		return fmt.Errorf("could not find File ID=%d: %w", fileID, err)
 	}
 
 	// 2) Update status = "processing"
-	if err := db.DB.Model(&frec).Update("status", "processing").Error; err != nil {
+	if err := db.DB.Model(&frec).Updates(map[string]interface{}{
+		"status":          "processing",
+		"stage":           "extracting",
+		"chunks_total":    nil,
+		"chunks_embedded": nil,
+	}).Error; err != nil {
 		log.Printf("[ProcessFile] failed to set processing status: %v\n", err)
 		// continue anyway
 	}
+	publishFile(fileID)
 
-	// 3) Extract text from disk
-	rawText := ""
-	ext := strings.ToLower(filepath.Ext(frec.StoragePath))
-	if ext == ".pdf" {
-		content, err := utils.ExtractTextFromPDF(frec.StoragePath)
-		if err != nil {
-			failFile(fileID, fmt.Errorf("PDF extract error: %w", err))
-			return
-		}
-		rawText = content
-	} else {
-		// treat everything else as plain text
-		b, err := os.ReadFile(frec.StoragePath)
-		if err != nil {
-			failFile(fileID, fmt.Errorf("text file read error: %w", err))
-			return
-		}
-		rawText = string(b)
+	// 3) Fetch the content from storage and extract its text (format is
+	//    sniffed from the content), split by heading
+	if frec.StorageKey == "" {
+		return permanentFailure(fileID, fmt.Errorf("file has no stored content"))
+	}
+	localPath, cleanup, err := storage.LocalPath(ctx, storage.Store, frec.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		return permanentFailure(fileID, fmt.Errorf("stored content is missing: %w", err))
+	}
+	if err != nil {
+		return fmt.Errorf("could not fetch file: %w", err)
+	}
+	sections, err := utils.ExtractDocument(localPath)
+	cleanup()
+	if err != nil {
+		return permanentFailure(fileID, fmt.Errorf("extract error: %w", err))
 	}
 
-	// 4) Chunk the text (2000 chars per chunk)
-	chunks := utils.ChunkText(rawText, 2000)
+	// 4) Chunk the text by tokens, keeping each chunk's heading path, and
+	//    record the settings used
+	setStage(fileID, "chunking")
+	opts := chunkOptions()
+	chunks := utils.ChunkSections(sections, opts)
 	if len(chunks) == 0 {
-		failFile(fileID, fmt.Errorf("no text chunks produced"))
-		return
+		return permanentFailure(fileID, fmt.Errorf("no text chunks produced"))
+	}
+	encoding := utils.TokenEncoding
+	total := len(chunks)
+	if err := db.DB.Model(&frec).Updates(File{
+		ChunkEncoding:      &encoding,
+		ChunkMaxTokens:     &opts.MaxTokens,
+		ChunkOverlapTokens: &opts.OverlapTokens,
+		ChunksTotal:        &total,
+	}).Error; err != nil {
+		log.Printf("[ProcessFile] could not record chunk settings: %v\n", err)
 	}
 
-	// 5) For each chunk: insert FileChunk row (with Embedding == nil), then compute embedding
-	for idx, txt := range chunks {
-		ch := FileChunk{
-			FileID:     fileID,
-			ChunkIndex: idx,
-			Content:    txt,
+	// 5) Upsert the FileChunk rows, then embed the ones without an embedding
+	//    in concurrent, rate-limited batches
+	rows := make([]FileChunk, len(chunks))
+	for idx, c := range chunks {
+		rows[idx] = FileChunk{
+			FileID:      fileID,
+			ChunkIndex:  idx,
+			Content:     c.Text,
+			TokenCount:  c.Tokens,
+			HeadingPath: utils.JoinHeadingPath(c.HeadingPath),
+			StartMs:     spanMs(c.Span, true),
+			EndMs:       spanMs(c.Span, false),
+			PageStart:   pageOf(c.Pages, true),
+			PageEnd:     pageOf(c.Pages, false),
 			// Embedding is left nil here → INSERT will set embedding = NULL
 		}
-		if err := db.DB.Create(&ch).Error; err != nil {
-			log.Printf("[ProcessFile] could not create chunk row: %v\n", err)
-			// skip this chunk
-			continue
-		}
+	}
+	if err := upsertChunks(fileID, rows); err != nil {
+		return fmt.Errorf("could not store chunks: %w", err)
+	}
 
-		// 5.a) Compute embedding
-		embedVec, err := ai.GetEmbedding(txt)
-		if err != nil {
-			log.Printf("[ProcessFile] GetEmbedding error (chunk %d): %v\n", idx, err)
-			// but keep going for other chunks
-			continue
+	var missing []FileChunk
+	if err := db.DB.Where("file_id = ? AND embedding IS NULL", fileID).
+		Order("chunk_index ASC").
+		Find(&missing).Error; err != nil {
+		return fmt.Errorf("could not load chunks to embed: %w", err)
+	}
+	// Chunks whose text is unchanged kept their embeddings.
+	if err := db.DB.Model(&frec).Updates(map[string]interface{}{
+		"stage":           "embedding",
+		"chunks_embedded": len(chunks) - len(missing),
+	}).Error; err != nil {
+		log.Printf("[ProcessFile] could not record progress: %v\n", err)
+	}
+	publishFile(fileID)
+	if len(missing) > 0 {
+		stats := embedChunks(ctx, missing)
+		log.Printf("[ProcessFile] file %d: %s\n", fileID, stats)
+		embedMs := stats.Duration.Milliseconds()
+		if err := db.DB.Model(&frec).Updates(File{
+			EmbedRequests: &stats.Requests,
+			EmbedTokens:   &stats.Tokens,
+			EmbedMs:       &embedMs,
+		}).Error; err != nil {
+			log.Printf("[ProcessFile] could not record embedding metrics: %v\n", err)
 		}
-
-		// 5.b) Convert []float32 → pgvector.Vector, then take address
-		vec := pgvector.NewVector(embedVec)
-		// ch.Embedding = &vec // (if you want to keep ch in memory). But we can also directly do an UPDATE:
-		if err := db.DB.Model(&FileChunk{}).
-			Where("id = ?", ch.ID).
-			Update("embedding", &vec).Error; err != nil {
-			log.Printf("[ProcessFile] could not save embedding for chunk %d: %v\n", idx, err)
+		if stats.Failed > 0 {
+			return fmt.Errorf("%d of %d chunks could not be embedded", stats.Failed, len(chunks))
 		}
 	}
 
-	// 6) Once at least one chunk is inserted, generate a bucket name
+	// 6) Once the chunks are stored, generate a bucket name
+	setStage(fileID, "naming")
 	var firstChunks []FileChunk
 	if err := db.DB.Where("file_id = ?", fileID).
 		Order("chunk_index ASC").
@@ -123,13 +174,176 @@ func ProcessFile(fileID uint) {
 	}
 
 	// 7) Finally, mark file as "completed"
-	if err := db.DB.Model(&frec).Update("status", "completed").Error; err != nil {
-		log.Printf("[ProcessFile] failed to set completed status: %v\n", err)
+	if err := db.DB.Model(&frec).Updates(map[string]interface{}{
+		"status":    "completed",
+		"error_msg": nil,
+		"stage":     nil,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to set completed status: %w", err)
+	}
+	publishFile(fileID)
+	return nil
+}
+
+// upsertChunks writes a file's chunks keyed by (file_id, chunk_index). A
+// chunk whose text is unchanged keeps its row, ID and embedding (only its
+// metadata is updated). A chunk whose text changed, or that is past the new
+// end (from an earlier run with different settings), is deleted, and a new
+// row takes its place; citations of the old row then resolve as removed
+// instead of pointing at different text.
+func upsertChunks(fileID uint, rows []FileChunk) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		var existing []FileChunk
+		if err := tx.Select("id", "chunk_index", "content").
+			Where("file_id = ?", fileID).
+			Find(&existing).Error; err != nil {
+			return err
+		}
+		if stale := staleChunkIDs(existing, rows); len(stale) > 0 {
+			if err := tx.Unscoped().Where("id IN ?", stale).Delete(&FileChunk{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "file_id"}, {Name: "chunk_index"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "deleted_at IS NULL"}}},
+			DoUpdates: clause.AssignmentColumns([]string{
+				"token_count", "heading_path", "start_ms", "end_ms", "page_start", "page_end", "updated_at",
+			}),
+		}).CreateInBatches(&rows, 500).Error
+	})
+}
+
+// staleChunkIDs returns the IDs of existing chunks that rows don't keep:
+// those whose index now holds different text, or no chunk at all.
+func staleChunkIDs(existing, rows []FileChunk) []uint {
+	var stale []uint
+	for _, c := range existing {
+		if c.ChunkIndex < 0 || c.ChunkIndex >= len(rows) || rows[c.ChunkIndex].Content != c.Content {
+			stale = append(stale, c.ID)
+		}
+	}
+	return stale
+}
+
+// DedupeChunks soft-deletes duplicate (file_id, chunk_index) rows left by
+// earlier, non-idempotent runs, so the unique index on them can be built.
+// Of each set of duplicates it keeps an embedded row, preferring the newest.
+// It only does work while that index is missing.
+func DedupeChunks() error {
+	m := db.DB.Migrator()
+	if !m.HasTable(&FileChunk{}) || m.HasIndex(&FileChunk{}, "idx_file_chunks_file_chunk") {
+		return nil
+	}
+	return db.DB.Exec(`
+		UPDATE file_chunks SET deleted_at = NOW()
+		WHERE id IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (
+					PARTITION BY file_id, chunk_index
+					ORDER BY (embedding IS NULL), id DESC
+				) AS rn
+				FROM file_chunks
+				WHERE deleted_at IS NULL
+			) ranked
+			WHERE rn > 1
+		)
+	`).Error
+}
+
+// setStage records which step of processing a file has reached.
+func setStage(fileID uint, stage string) {
+	if err := db.DB.Model(&File{}).Where("id = ?", fileID).Update("stage", stage).Error; err != nil {
+		log.Printf("[ProcessFile] could not record stage %q: %v\n", stage, err)
+	}
+	publishFile(fileID)
+}
+
+// fileEvent is the status of a file as sent to event stream clients.
+type fileEvent struct {
+	ID             uint    `json:"id"`
+	Filename       string  `json:"filename"`
+	Status         string  `json:"status"` // as File.Status, or "deleted"
+	Stage          *string `json:"stage,omitempty"`
+	ChunksTotal    *int    `json:"chunksTotal,omitempty"`
+	ChunksEmbedded *int    `json:"chunksEmbedded,omitempty"`
+	Error          *string `json:"error,omitempty"`
+}
+
+// publishFile announces a file's current status to its bucket's event
+// stream.
+func publishFile(fileID uint) {
+	if !events.Enabled() {
+		return
+	}
+	var f File
+	if err := db.DB.Unscoped().First(&f, fileID).Error; err != nil {
+		return
+	}
+	ev := fileEvent{
+		ID:             f.ID,
+		Filename:       f.Filename,
+		Status:         f.Status,
+		Stage:          f.Stage,
+		ChunksTotal:    f.ChunksTotal,
+		ChunksEmbedded: f.ChunksEmbedded,
+		Error:          f.ErrorMsg,
+	}
+	if f.DeletedAt.Valid {
+		ev.Status = "deleted"
+	}
+	events.Publish(f.BucketID, "file", ev)
+}
+
+// permanentFailure marks the file failed and tells asynq not to retry.
+func permanentFailure(fileID uint, err error) error {
+	FailFile(fileID, err)
+	return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+}
+
+// chunkOptions reads CHUNK_MAX_TOKENS and CHUNK_OVERLAP_TOKENS, falling
+// back to utils.DefaultChunkOptions for unset or invalid values. Overlap is
+// kept under half a chunk.
+func chunkOptions() utils.ChunkOptions {
+	opts := utils.ChunkOptions{
+		MaxTokens:     envInt("CHUNK_MAX_TOKENS", utils.DefaultChunkOptions.MaxTokens, 50, 8000),
+		OverlapTokens: envInt("CHUNK_OVERLAP_TOKENS", utils.DefaultChunkOptions.OverlapTokens, 0, 8000),
+	}
+	if opts.OverlapTokens > opts.MaxTokens/2 {
+		opts.OverlapTokens = opts.MaxTokens / 2
+	}
+	return opts
+}
+
+// spanMs returns the start (or end) of a chunk's time span in milliseconds,
+// or nil for untimed chunks.
+func spanMs(span *utils.TimeSpan, start bool) *int64 {
+	if span == nil {
+		return nil
+	}
+	d := span.End
+	if start {
+		d = span.Start
+	}
+	ms := d.Milliseconds()
+	return &ms
+}
+
+// pageOf returns the first (or last) page of a chunk's page range, or nil
+// for chunks that don't come from a PDF.
+func pageOf(pages *utils.PageRange, start bool) *int {
+	if pages == nil {
+		return nil
+	}
+	p := pages.End
+	if start {
+		p = pages.Start
 	}
+	return &p
 }
 
-// failFile updates file.status="failed" and records the error message.
-func failFile(fileID uint, procErr error) {
+// FailFile updates file.status="failed" and records the error message.
+func FailFile(fileID uint, procErr error) {
 	errMsg := procErr.Error()
 	_ = db.DB.Model(&File{}).
 		Where("id = ?", fileID).
@@ -138,4 +352,5 @@ func failFile(fileID uint, procErr error) {
 			"error_msg": &errMsg,
 		}).Error
 	log.Printf("[ProcessFile] file %d failed: %v\n", fileID, procErr)
+	publishFile(fileID)
 }
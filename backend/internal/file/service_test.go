@@ -0,0 +1,38 @@
+package file
+
+import (
+	"reflect"
+	"testing"
+)
+
+func chunks(contents ...string) []FileChunk {
+	out := make([]FileChunk, len(contents))
+	for i, c := range contents {
+		out[i] = FileChunk{ID: uint(100 + i), ChunkIndex: i, Content: c}
+	}
+	return out
+}
+
+func TestStaleChunkIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []FileChunk
+		rows     []FileChunk
+		want     []uint
+	}{
+		{"first run", nil, chunks("a", "b"), nil},
+		{"unchanged text keeps every row", chunks("a", "b"), chunks("a", "b"), nil},
+		{"edited chunk gets a new row", chunks("a", "b", "c"), chunks("a", "B", "c"), []uint{101}},
+		{"shorter text drops the tail", chunks("a", "b", "c"), chunks("a"), []uint{101, 102}},
+		{"longer text keeps the head", chunks("a"), chunks("a", "b"), nil},
+		{"inserted paragraph shifts the rest", chunks("a", "b"), chunks("x", "a", "b"), []uint{100, 101}},
+		{"no chunks left", chunks("a"), nil, []uint{100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := staleChunkIDs(tt.existing, tt.rows); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("staleChunkIDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
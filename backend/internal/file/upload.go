@@ -0,0 +1,149 @@
+// internal/file/upload.go
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/utils"
+)
+
+var (
+	ErrUploadTooLarge = errors.New("file is larger than the upload limit")
+	ErrQuotaExceeded  = errors.New("upload would exceed your storage quota")
+)
+
+// multipartSlack is what a request may carry besides the file itself:
+// part headers, boundaries and the small form fields.
+const multipartSlack = 1 << 20
+
+// uploadLimits reads MAX_UPLOAD_MB, the largest single upload (default
+// 100), and USER_STORAGE_QUOTA_MB, the most a user may store across their
+// buckets (default 1024; 0 turns the quota off).
+func uploadLimits() (maxUpload, quota int64) {
+	maxUpload = int64(envInt("MAX_UPLOAD_MB", 100, 1, 1<<20)) << 20
+	quota = int64(envInt("USER_STORAGE_QUOTA_MB", 1024, 0, 1<<30)) << 20
+	return maxUpload, quota
+}
+
+// quotaLeft is how many more bytes userID may store, or math.MaxInt64 when
+// there is no quota.
+func quotaLeft(userID uint, quota int64) (int64, error) {
+	return quotaLeftExcluding(userID, quota, 0)
+}
+
+// quotaLeftExcluding is quotaLeft with reserved bytes of what storageUsed
+// counts set aside, for an upload session turning its reservation into a
+// file or for content about to be overwritten.
+func quotaLeftExcluding(userID uint, quota, reserved int64) (int64, error) {
+	if quota == 0 {
+		return math.MaxInt64, nil
+	}
+	used, err := storageUsed(userID)
+	if err != nil {
+		return 0, err
+	}
+	return quotaRemaining(quota, used-reserved), nil
+}
+
+// quotaRemaining is what is left of quota after used bytes, never negative;
+// a quota of 0 means no limit.
+func quotaRemaining(quota, used int64) int64 {
+	if quota == 0 {
+		return math.MaxInt64
+	}
+	return max(quota-used, 0)
+}
+
+// storageUsed is the size of everything userID has stored, plus the
+// declared size of their unexpired upload sessions, which reserve room for
+// what is still being sent. Copies of a file share its content, so each
+// stored blob is counted once.
+func storageUsed(userID uint) (int64, error) {
+	var used int64
+	err := db.DB.Raw(`
+		SELECT
+			(SELECT COALESCE(SUM(size_bytes), 0) FROM (
+				SELECT DISTINCT f.storage_key, f.size_bytes
+				FROM files f
+				JOIN buckets b ON b.id = f.bucket_id AND b.deleted_at IS NULL
+				WHERE b.user_id = ? AND f.deleted_at IS NULL
+			) stored)
+			+
+			(SELECT COALESCE(SUM(size), 0) FROM upload_sessions
+				WHERE user_id = ? AND expires_at > NOW())
+	`, userID, userID).Scan(&used).Error
+	return used, err
+}
+
+// sizeCap passes reads through until more than limit bytes have been read,
+// then fails with err, so an oversized upload is abandoned mid-stream.
+type sizeCap struct {
+	r     io.Reader
+	limit int64
+	n     int64
+	err   error
+}
+
+func (c *sizeCap) Read(p []byte) (int, error) {
+	if c.n > c.limit {
+		return 0, c.err
+	}
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.n > c.limit {
+		return n, c.err
+	}
+	return n, err
+}
+
+// exceeded reports whether the reader hit its limit.
+func (c *sizeCap) exceeded() bool {
+	return c.n > c.limit
+}
+
+// newBlobKey returns a fresh storage key for a file named name in a
+// bucket, keeping its extension (format detection consults it).
+func newBlobKey(userID, bucketID uint, name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if len(ext) > 16 || strings.ContainsAny(ext, `/\`) {
+		ext = ""
+	}
+	return bucketKey(userID, bucketID, uuid.NewString()+ext)
+}
+
+// sizeError phrases a limit error with the limit, e.g. "file is larger
+// than the upload limit (100 MB)".
+func sizeError(err error, limit int64) string {
+	return fmt.Sprintf("%v (%d MB)", err, limit>>20)
+}
+
+// sniffFile returns the sniffed media type of the local file at p, named
+// name, or "" if it can't be read.
+func sniffFile(p, name string) string {
+	f, err := os.Open(p)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	contentType, _ := utils.SniffContentType(head[:n], name)
+	return contentType
+}
+
+// nullIfEmpty maps "" to NULL for column updates.
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
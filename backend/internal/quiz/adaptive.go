@@ -0,0 +1,263 @@
+// internal/quiz/adaptive.go
+package quiz
+
+import (
+	"errors"
+	"math"
+
+	"gorm.io/gorm"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+)
+
+// defaultAdaptiveLength is the fixed-length stopping rule used when an
+// adaptive quiz doesn't set QuestionCount.
+const defaultAdaptiveLength = 20
+
+// adaptivePriorVar is the variance of the N(0, σ²) prior on a learner's
+// ability at the start of an adaptive attempt.
+const adaptivePriorVar = 1.0
+
+var (
+	// ErrNoAdaptivePool is returned when a bucket has no questions to adapt over.
+	ErrNoAdaptivePool = errors.New("no questions in this bucket to build an adaptive quiz from")
+	// ErrAttemptCompleted is returned when answering a finished attempt.
+	ErrAttemptCompleted = errors.New("attempt is already completed")
+	// ErrNotCurrentQuestion is returned when the answer isn't for the question
+	// most recently served by GET /attempts/{attemptId}/next.
+	ErrNotCurrentQuestion = errors.New("question is not the one currently being asked")
+	// ErrInvalidAnswer is returned when the answer doesn't belong to the question.
+	ErrInvalidAnswer = errors.New("answer does not belong to question")
+	// ErrAlreadyAnswered is returned when a concurrent request answered the
+	// current question first.
+	ErrAlreadyAnswered = errors.New("question was already answered")
+)
+
+// AdaptivePool returns the original (non-copied) questions from every quiz
+// in the bucket. Uncalibrated questions are assumed to be of average
+// difficulty (0 logits) until CalibrateItems has seen enough answers.
+func AdaptivePool(bucketID uint) ([]Question, error) {
+	var qs []Question
+	err := db.DB.Model(&Question{}).
+		Select("questions.*").
+		Joins("JOIN quizzes ON quizzes.id = questions.quiz_id").
+		Where("quizzes.bucket_id = ? AND quizzes.deleted_at IS NULL", bucketID).
+		Where("questions.origin_question_id IS NULL").
+		Order("questions.id ASC").
+		Find(&qs).Error
+	return qs, err
+}
+
+// prepareAdaptive checks that an adaptive quiz has something to ask. Its
+// questions are chosen one at a time per attempt, so nothing is inserted.
+func prepareAdaptive(qrec Quiz) error {
+	pool, err := AdaptivePool(qrec.BucketID)
+	if err != nil {
+		return err
+	}
+	if len(pool) == 0 {
+		return ErrNoAdaptivePool
+	}
+	return nil
+}
+
+// questionDifficulty is the calibrated difficulty, or 0 when uncalibrated.
+func questionDifficulty(q Question) float64 {
+	if q.Difficulty != nil {
+		return *q.Difficulty
+	}
+	return 0
+}
+
+// adaptiveLength is the maximum number of questions in an adaptive attempt.
+func adaptiveLength(qrec Quiz) int {
+	if qrec.QuestionCount > 0 {
+		return qrec.QuestionCount
+	}
+	return defaultAdaptiveLength
+}
+
+// adaptiveDone applies the quiz's stopping rule.
+func adaptiveDone(qrec Quiz, answered int, stdErr float64) bool {
+	if answered >= adaptiveLength(qrec) {
+		return true
+	}
+	return qrec.TargetStdErr != nil && answered > 0 && stdErr <= *qrec.TargetStdErr
+}
+
+// StartAdaptiveAttempt creates an in-progress attempt at the prior ability.
+func StartAdaptiveAttempt(qrec Quiz, userID uint) (Attempt, error) {
+	ability, stdErr := estimateAbility(nil, 0, adaptivePriorVar)
+	att := Attempt{
+		QuizID:        qrec.ID,
+		UserID:        userID,
+		Status:        AttemptInProgress,
+		Ability:       &ability,
+		AbilityStdErr: &stdErr,
+	}
+	err := db.DB.Create(&att).Error
+	return att, err
+}
+
+// NextAdaptiveQuestion returns the question the learner should answer next:
+// the unasked pool question whose difficulty is closest to the current
+// ability estimate (where a Rasch item is most informative). It returns nil
+// once the stopping rule is met or the pool is exhausted, completing the
+// attempt. Calling it again before answering returns the same question.
+func NextAdaptiveQuestion(att *Attempt, qrec Quiz) (*Question, error) {
+	if att.Status == AttemptCompleted {
+		return nil, nil
+	}
+	if att.CurrentQuestionID != nil {
+		var q Question
+		if err := db.DB.First(&q, *att.CurrentQuestionID).Error; err == nil {
+			return &q, nil
+		}
+		// The question vanished; fall through and pick another.
+	}
+
+	var answered []AttemptAnswer
+	if err := db.DB.Where("attempt_id = ?", att.ID).Find(&answered).Error; err != nil {
+		return nil, err
+	}
+	stdErr := math.Sqrt(adaptivePriorVar)
+	if att.AbilityStdErr != nil {
+		stdErr = *att.AbilityStdErr
+	}
+	if adaptiveDone(qrec, len(answered), stdErr) {
+		return nil, completeAdaptiveAttempt(att)
+	}
+
+	pool, err := AdaptivePool(qrec.BucketID)
+	if err != nil {
+		return nil, err
+	}
+	asked := make(map[uint]bool, len(answered))
+	for _, a := range answered {
+		asked[a.QuestionID] = true
+	}
+	var ability float64
+	if att.Ability != nil {
+		ability = *att.Ability
+	}
+
+	var best *Question
+	bestDist := math.Inf(1)
+	for i := range pool {
+		if asked[pool[i].ID] {
+			continue
+		}
+		if d := math.Abs(questionDifficulty(pool[i]) - ability); d < bestDist {
+			best, bestDist = &pool[i], d
+		}
+	}
+	if best == nil {
+		return nil, completeAdaptiveAttempt(att)
+	}
+
+	if err := db.DB.Model(att).Update("current_question_id", best.ID).Error; err != nil {
+		return nil, err
+	}
+	att.CurrentQuestionID = &best.ID
+	return best, nil
+}
+
+// AnswerAdaptiveQuestion records the answer to the current question,
+// re-estimates ability from every answer so far, and completes the attempt
+// if the stopping rule is now met. It reports whether the answer was correct.
+func AnswerAdaptiveQuestion(att *Attempt, qrec Quiz, questionID, answerID uint, timeSpentMs *int) (bool, error) {
+	if att.Status == AttemptCompleted {
+		return false, ErrAttemptCompleted
+	}
+	if att.CurrentQuestionID == nil || *att.CurrentQuestionID != questionID {
+		return false, ErrNotCurrentQuestion
+	}
+
+	var arec Answer
+	if err := db.DB.Where("id = ? AND question_id = ?", answerID, questionID).First(&arec).Error; err != nil {
+		return false, ErrInvalidAnswer
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		// Claim the question first: of concurrent submits for it, only the
+		// one whose update still finds it current records an answer.
+		claim := tx.Model(&Attempt{}).
+			Where("id = ? AND status = ? AND current_question_id = ?", att.ID, AttemptInProgress, questionID).
+			Update("current_question_id", nil)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			return ErrAlreadyAnswered
+		}
+
+		aa := AttemptAnswer{
+			AttemptID:   att.ID,
+			QuestionID:  questionID,
+			AnswerID:    answerID,
+			IsCorrect:   arec.IsCorrect,
+			TimeSpentMs: timeSpentMs,
+		}
+		if err := tx.Create(&aa).Error; err != nil {
+			return err
+		}
+
+		var rows []struct {
+			IsCorrect  bool
+			Difficulty *float64
+		}
+		if err := tx.Table("attempt_answers").
+			Select("attempt_answers.is_correct, questions.difficulty").
+			Joins("JOIN questions ON questions.id = attempt_answers.question_id").
+			Where("attempt_answers.attempt_id = ? AND attempt_answers.deleted_at IS NULL", att.ID).
+			Scan(&rows).Error; err != nil {
+			return err
+		}
+
+		items := make([]scoredItem, 0, len(rows))
+		correct := 0
+		for _, r := range rows {
+			it := scoredItem{Correct: r.IsCorrect}
+			if r.Difficulty != nil {
+				it.Difficulty = *r.Difficulty
+			}
+			if r.IsCorrect {
+				correct++
+			}
+			items = append(items, it)
+		}
+		ability, stdErr := estimateAbility(items, 0, adaptivePriorVar)
+
+		// Score keeps the raw percentage so history views stay comparable;
+		// the ability estimate is what adaptive attempts report.
+		updates := map[string]interface{}{
+			"ability":         ability,
+			"ability_std_err": stdErr,
+			"score":           float64(correct) / float64(len(items)) * 100,
+		}
+		if adaptiveDone(qrec, len(items), stdErr) {
+			updates["status"] = AttemptCompleted
+		}
+		if err := tx.Model(att).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.First(att, att.ID).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	return arec.IsCorrect, nil
+}
+
+// completeAdaptiveAttempt marks the attempt completed, keeping its estimate.
+func completeAdaptiveAttempt(att *Attempt) error {
+	if err := db.DB.Model(att).Updates(map[string]interface{}{
+		"status":              AttemptCompleted,
+		"current_question_id": nil,
+	}).Error; err != nil {
+		return err
+	}
+	att.Status = AttemptCompleted
+	att.CurrentQuestionID = nil
+	return nil
+}
@@ -0,0 +1,269 @@
+// internal/quiz/analytics.go
+package quiz
+
+import (
+	"math"
+	"sort"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+)
+
+// AnswerStats reports how often one answer choice was selected.
+type AnswerStats struct {
+	AnswerID  uint    `json:"answerId"`
+	Text      string  `json:"text"`
+	IsCorrect bool    `json:"isCorrect"`
+	Count     int     `json:"count"`
+	Percent   float64 `json:"percent"`
+}
+
+// QuestionStats summarizes every response given to one question.
+type QuestionStats struct {
+	QuestionID     uint          `json:"questionId"`
+	Text           string        `json:"text"`
+	Responses      int           `json:"responses"`
+	PercentCorrect float64       `json:"percentCorrect"`
+	Discrimination *float64      `json:"discrimination"`      // item-rest point-biserial; nil when undefined
+	Difficulty     *float64      `json:"difficulty"`          // calibrated Rasch difficulty; nil until calibrated
+	AvgTimeMs      *float64      `json:"avgTimeMs,omitempty"` // only when clients reported timings
+	Answers        []AnswerStats `json:"answers"`
+}
+
+// HistogramBin counts attempts whose score falls in [Min, Max).
+// The last bin also includes Max (a perfect 100).
+type HistogramBin struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"count"`
+}
+
+// ScoreDistribution describes the spread of attempt scores for a quiz.
+type ScoreDistribution struct {
+	Attempts  int            `json:"attempts"`
+	Mean      float64        `json:"mean"`
+	Median    float64        `json:"median"`
+	StdDev    float64        `json:"stdDev"`
+	Min       float64        `json:"min"`
+	Max       float64        `json:"max"`
+	Histogram []HistogramBin `json:"histogram"`
+}
+
+// QuizAnalytics is the payload returned by GET /quizzes/{quizId}/analytics.
+type QuizAnalytics struct {
+	QuizID    uint              `json:"quizId"`
+	Scores    ScoreDistribution `json:"scores"`
+	Questions []QuestionStats   `json:"questions"`
+}
+
+// histogramBins is the number of equal-width bins between 0 and 100.
+const histogramBins = 10
+
+// LoadQuizAnalytics reads the quiz's questions, answers, attempts and
+// attempt answers and aggregates them with computeQuizAnalytics.
+func LoadQuizAnalytics(quizID uint) (QuizAnalytics, error) {
+	var questions []Question
+	if err := db.DB.Where("quiz_id = ?", quizID).Order("id ASC").Find(&questions).Error; err != nil {
+		return QuizAnalytics{}, err
+	}
+	questionIDs := make([]uint, 0, len(questions))
+	for _, q := range questions {
+		questionIDs = append(questionIDs, q.ID)
+	}
+
+	var answers []Answer
+	if len(questionIDs) > 0 {
+		if err := db.DB.Where("question_id IN ?", questionIDs).Order("id ASC").Find(&answers).Error; err != nil {
+			return QuizAnalytics{}, err
+		}
+	}
+
+	var attempts []Attempt
+	if err := db.DB.Where("quiz_id = ? AND status = ?", quizID, AttemptCompleted).Find(&attempts).Error; err != nil {
+		return QuizAnalytics{}, err
+	}
+	attemptIDs := make([]uint, 0, len(attempts))
+	for _, a := range attempts {
+		attemptIDs = append(attemptIDs, a.ID)
+	}
+
+	var responses []AttemptAnswer
+	if len(attemptIDs) > 0 {
+		if err := db.DB.Where("attempt_id IN ?", attemptIDs).Find(&responses).Error; err != nil {
+			return QuizAnalytics{}, err
+		}
+	}
+
+	out := computeQuizAnalytics(questions, answers, attempts, responses)
+	out.QuizID = quizID
+	return out, nil
+}
+
+// computeQuizAnalytics does the actual aggregation. It is kept free of
+// database access so the statistics can be reasoned about on their own.
+func computeQuizAnalytics(questions []Question, answers []Answer, attempts []Attempt, responses []AttemptAnswer) QuizAnalytics {
+	// Total correct per attempt, used as the "test score" for discrimination.
+	totals := make(map[uint]int)
+	for _, r := range responses {
+		if r.IsCorrect {
+			totals[r.AttemptID]++
+		}
+	}
+
+	byQuestion := make(map[uint][]AttemptAnswer)
+	for _, r := range responses {
+		byQuestion[r.QuestionID] = append(byQuestion[r.QuestionID], r)
+	}
+	answersByQuestion := make(map[uint][]Answer)
+	for _, a := range answers {
+		answersByQuestion[a.QuestionID] = append(answersByQuestion[a.QuestionID], a)
+	}
+
+	var out QuizAnalytics
+	out.Scores = scoreDistribution(attempts)
+	out.Questions = make([]QuestionStats, 0, len(questions))
+	for _, q := range questions {
+		rs := byQuestion[q.ID]
+		qs := QuestionStats{
+			QuestionID: q.ID,
+			Text:       q.Text,
+			Responses:  len(rs),
+			Difficulty: q.Difficulty,
+		}
+
+		counts := make(map[uint]int)
+		correct := 0
+		timeSum, timeN := 0, 0
+		for _, r := range rs {
+			counts[r.AnswerID]++
+			if r.IsCorrect {
+				correct++
+			}
+			if r.TimeSpentMs != nil {
+				timeSum += *r.TimeSpentMs
+				timeN++
+			}
+		}
+		if len(rs) > 0 {
+			qs.PercentCorrect = float64(correct) / float64(len(rs)) * 100
+		}
+		if timeN > 0 {
+			avg := float64(timeSum) / float64(timeN)
+			qs.AvgTimeMs = &avg
+		}
+		qs.Discrimination = pointBiserial(rs, totals)
+
+		for _, a := range answersByQuestion[q.ID] {
+			as := AnswerStats{
+				AnswerID:  a.ID,
+				Text:      a.Text,
+				IsCorrect: a.IsCorrect,
+				Count:     counts[a.ID],
+			}
+			if len(rs) > 0 {
+				as.Percent = float64(as.Count) / float64(len(rs)) * 100
+			}
+			qs.Answers = append(qs.Answers, as)
+		}
+		out.Questions = append(out.Questions, qs)
+	}
+	return out
+}
+
+// pointBiserial returns the corrected (item-rest) point-biserial correlation
+// between answering this question correctly and the number of other
+// questions the same attempt got right. It returns nil when the statistic is
+// undefined: fewer than two responses, everyone right/wrong, or no variance
+// in rest scores.
+func pointBiserial(rs []AttemptAnswer, totals map[uint]int) *float64 {
+	n := len(rs)
+	if n < 2 {
+		return nil
+	}
+
+	rest := make([]float64, n)
+	var sum1, sum0 float64
+	n1 := 0
+	for i, r := range rs {
+		item := 0
+		if r.IsCorrect {
+			item = 1
+		}
+		rest[i] = float64(totals[r.AttemptID] - item)
+		if r.IsCorrect {
+			sum1 += rest[i]
+			n1++
+		} else {
+			sum0 += rest[i]
+		}
+	}
+	n0 := n - n1
+	if n1 == 0 || n0 == 0 {
+		return nil
+	}
+
+	_, sd := meanStdDev(rest)
+	if sd == 0 {
+		return nil
+	}
+	p := float64(n1) / float64(n)
+	r := (sum1/float64(n1) - sum0/float64(n0)) / sd * math.Sqrt(p*(1-p))
+	return &r
+}
+
+// scoreDistribution computes summary statistics and a 10-point histogram
+// over attempt scores (which are percentages).
+func scoreDistribution(attempts []Attempt) ScoreDistribution {
+	d := ScoreDistribution{Attempts: len(attempts)}
+	width := 100.0 / histogramBins
+	for i := 0; i < histogramBins; i++ {
+		d.Histogram = append(d.Histogram, HistogramBin{
+			Min: float64(i) * width,
+			Max: float64(i+1) * width,
+		})
+	}
+	if len(attempts) == 0 {
+		return d
+	}
+
+	scores := make([]float64, 0, len(attempts))
+	for _, a := range attempts {
+		scores = append(scores, a.Score)
+		bin := int(a.Score / width)
+		if bin >= histogramBins {
+			bin = histogramBins - 1
+		}
+		if bin < 0 {
+			bin = 0
+		}
+		d.Histogram[bin].Count++
+	}
+	sort.Float64s(scores)
+
+	d.Mean, d.StdDev = meanStdDev(scores)
+	d.Min = scores[0]
+	d.Max = scores[len(scores)-1]
+	mid := len(scores) / 2
+	if len(scores)%2 == 0 {
+		d.Median = (scores[mid-1] + scores[mid]) / 2
+	} else {
+		d.Median = scores[mid]
+	}
+	return d
+}
+
+// meanStdDev returns the mean and population standard deviation of xs.
+func meanStdDev(xs []float64) (float64, float64) {
+	if len(xs) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	mean := sum / float64(len(xs))
+	var ss float64
+	for _, x := range xs {
+		ss += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(ss / float64(len(xs)))
+}
@@ -0,0 +1,138 @@
+package quiz
+
+import (
+	"math"
+	"testing"
+)
+
+// itemResponses builds one question's responses, one per attempt, with
+// attempt IDs numbered from 1.
+func itemResponses(correct ...bool) []AttemptAnswer {
+	rs := make([]AttemptAnswer, len(correct))
+	for i, c := range correct {
+		rs[i] = AttemptAnswer{AttemptID: uint(i + 1), QuestionID: 1, IsCorrect: c}
+	}
+	return rs
+}
+
+func TestPointBiserial(t *testing.T) {
+	tests := []struct {
+		name   string
+		rs     []AttemptAnswer
+		totals map[uint]int // correct answers per attempt, this item included
+		want   *float64
+	}{
+		{
+			// Rest scores 2, 1 (correct) and 1, 0 (wrong): mean gap 1, sd √½.
+			name:   "stronger attempts get it right",
+			rs:     itemResponses(true, true, false, false),
+			totals: map[uint]int{1: 3, 2: 2, 3: 1, 4: 0},
+			want:   floatPtr(math.Sqrt(0.5)),
+		},
+		{
+			// Rest scores 0, 0 (correct) and 2, 2 (wrong): mean gap −2, sd 1.
+			name:   "weaker attempts get it right",
+			rs:     itemResponses(false, false, true, true),
+			totals: map[uint]int{1: 2, 2: 2, 3: 1, 4: 1},
+			want:   floatPtr(-1),
+		},
+		{
+			name:   "single response",
+			rs:     itemResponses(true),
+			totals: map[uint]int{1: 1},
+		},
+		{
+			name:   "everyone right",
+			rs:     itemResponses(true, true, true),
+			totals: map[uint]int{1: 3, 2: 1, 3: 2},
+		},
+		{
+			name:   "everyone wrong",
+			rs:     itemResponses(false, false),
+			totals: map[uint]int{1: 2, 2: 0},
+		},
+		{
+			name:   "no variance in rest scores",
+			rs:     itemResponses(true, false),
+			totals: map[uint]int{1: 2, 2: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pointBiserial(tt.rs, tt.totals)
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("pointBiserial = %v, want nil", *got)
+			case tt.want != nil && got == nil:
+				t.Errorf("pointBiserial = nil, want %v", *tt.want)
+			case tt.want != nil && math.Abs(*got-*tt.want) > 1e-9:
+				t.Errorf("pointBiserial = %v, want %v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreDistribution(t *testing.T) {
+	attempts := func(scores ...float64) []Attempt {
+		out := make([]Attempt, len(scores))
+		for i, s := range scores {
+			out[i] = Attempt{Score: s}
+		}
+		return out
+	}
+	tests := []struct {
+		name   string
+		scores []float64
+		want   ScoreDistribution // summary fields only; bins are checked via counts
+		counts map[int]int       // bin index → count; other bins must be empty
+	}{
+		{
+			name: "no attempts",
+		},
+		{
+			name:   "even count",
+			scores: []float64{100, 0, 55, 100},
+			want:   ScoreDistribution{Attempts: 4, Mean: 63.75, Median: 77.5, Min: 0, Max: 100, StdDev: math.Sqrt((63.75*63.75 + 8.75*8.75 + 2*36.25*36.25) / 4)},
+			counts: map[int]int{0: 1, 5: 1, 9: 2},
+		},
+		{
+			name:   "odd count and bin edges",
+			scores: []float64{10, 19.99, 20},
+			want:   ScoreDistribution{Attempts: 3, Mean: 49.99 / 3, Median: 19.99, Min: 10, Max: 20, StdDev: math.Sqrt(((10-49.99/3)*(10-49.99/3) + (19.99-49.99/3)*(19.99-49.99/3) + (20-49.99/3)*(20-49.99/3)) / 3)},
+			counts: map[int]int{1: 2, 2: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := scoreDistribution(attempts(tt.scores...))
+			if d.Attempts != tt.want.Attempts {
+				t.Errorf("Attempts = %d, want %d", d.Attempts, tt.want.Attempts)
+			}
+			for _, f := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"Mean", d.Mean, tt.want.Mean},
+				{"Median", d.Median, tt.want.Median},
+				{"StdDev", d.StdDev, tt.want.StdDev},
+				{"Min", d.Min, tt.want.Min},
+				{"Max", d.Max, tt.want.Max},
+			} {
+				if math.Abs(f.got-f.want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", f.name, f.got, f.want)
+				}
+			}
+			if len(d.Histogram) != histogramBins {
+				t.Fatalf("%d histogram bins, want %d", len(d.Histogram), histogramBins)
+			}
+			for i, bin := range d.Histogram {
+				if bin.Min != float64(i)*10 || bin.Max != float64(i+1)*10 {
+					t.Errorf("bin %d spans [%v, %v)", i, bin.Min, bin.Max)
+				}
+				if bin.Count != tt.counts[i] {
+					t.Errorf("bin %d has %d attempts, want %d", i, bin.Count, tt.counts[i])
+				}
+			}
+		})
+	}
+}
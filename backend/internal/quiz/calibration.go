@@ -0,0 +1,177 @@
+// internal/quiz/calibration.go
+package quiz
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+)
+
+// minCalibrationResponses is how many answers an item needs before its
+// fitted difficulty is trusted enough to store.
+const minCalibrationResponses = 5
+
+// calibrationMaxIter and calibrationTol bound the Rasch fit.
+const (
+	calibrationMaxIter = 100
+	calibrationTol     = 1e-4
+)
+
+// defaultBankQuestions is used when a bank quiz doesn't ask for a count.
+const defaultBankQuestions = 10
+
+// Named difficulty bands, in logits. An average learner answers an "easy"
+// item correctly more than ~73% of the time and a "hard" one less than ~27%.
+var difficultyBands = map[string][2]*float64{
+	"easy":   {nil, floatPtr(-1)},
+	"medium": {floatPtr(-1), floatPtr(1)},
+	"hard":   {floatPtr(1), nil},
+}
+
+// ErrNoBankQuestions is returned when no calibrated question in the bucket
+// falls inside the requested difficulty band.
+var ErrNoBankQuestions = errors.New("no calibrated questions in the requested difficulty band")
+
+func floatPtr(f float64) *float64 { return &f }
+
+// DifficultyBand resolves a named band ("easy", "medium", "hard").
+func DifficultyBand(name string) (min, max *float64, ok bool) {
+	band, ok := difficultyBands[name]
+	return band[0], band[1], ok
+}
+
+// CalibrateItems fits a Rasch model over every recorded answer and stores
+// the estimated difficulty on each question (and all its copies) and the
+// estimated ability of each learner. It is run periodically by the worker.
+func CalibrateItems() error {
+	var responses []irtResponse
+	if err := db.DB.Raw(`
+		SELECT
+			at.user_id AS person,
+			COALESCE(q.origin_question_id, q.id) AS item,
+			aa.is_correct AS correct
+		FROM attempt_answers aa
+		JOIN attempts at ON at.id = aa.attempt_id
+		JOIN questions q ON q.id = aa.question_id
+		WHERE at.status = ? AND aa.deleted_at IS NULL AND at.deleted_at IS NULL
+	`, AttemptCompleted).Scan(&responses).Error; err != nil {
+		return err
+	}
+	if len(responses) == 0 {
+		log.Printf("[quiz.CalibrateItems] no responses to calibrate\n")
+		return nil
+	}
+
+	fit := fitRasch(responses, calibrationMaxIter, calibrationTol)
+	if !fit.Converged {
+		log.Printf("[quiz.CalibrateItems] Rasch fit did not converge after %d iterations; storing last estimates\n", fit.Iterations)
+	}
+
+	now := time.Now()
+	calibrated := 0
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		for item, est := range fit.Difficulties {
+			updates := difficultyUpdates(est, now)
+			if _, ok := updates["difficulty"]; ok {
+				calibrated++
+			}
+			if err := tx.Model(&Question{}).
+				Where("id = ? OR origin_question_id = ?", item, item).
+				Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+
+		for userID, est := range fit.Abilities {
+			la := LearnerAbility{
+				UserID:    userID,
+				Ability:   est.Value,
+				StdErr:    est.StdErr,
+				Responses: est.N,
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"ability", "std_err", "responses", "updated_at"}),
+			}).Create(&la).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[quiz.CalibrateItems] fitted %d items (%d calibrated) and %d learners from %d responses in %d iterations\n",
+		len(fit.Difficulties), calibrated, len(fit.Abilities), len(responses), fit.Iterations)
+	return nil
+}
+
+// difficultyUpdates is what CalibrateItems stores for an item: always its
+// response count, and the fitted difficulty only once the item has at least
+// minCalibrationResponses answers.
+func difficultyUpdates(est raschEstimate, now time.Time) map[string]interface{} {
+	updates := map[string]interface{}{"difficulty_responses": est.N}
+	if est.N >= minCalibrationResponses {
+		updates["difficulty"] = est.Value
+		updates["difficulty_std_err"] = est.StdErr
+		updates["calibrated_at"] = now
+	}
+	return updates
+}
+
+// BankQuestions returns the bucket's calibrated original questions whose
+// difficulty lies within [min, max] (nil bounds are open).
+func BankQuestions(bucketID uint, min, max *float64) ([]Question, error) {
+	q := db.DB.Model(&Question{}).
+		Select("questions.*").
+		Joins("JOIN quizzes ON quizzes.id = questions.quiz_id").
+		Where("quizzes.bucket_id = ? AND quizzes.deleted_at IS NULL", bucketID).
+		Where("questions.origin_question_id IS NULL AND questions.difficulty IS NOT NULL")
+	if min != nil {
+		q = q.Where("questions.difficulty >= ?", *min)
+	}
+	if max != nil {
+		q = q.Where("questions.difficulty <= ?", *max)
+	}
+
+	var qs []Question
+	err := q.Order("questions.id ASC").Find(&qs).Error
+	return qs, err
+}
+
+// generateBank fills the quiz with a random sample of calibrated questions
+// from the requested difficulty band.
+func generateBank(qrec Quiz) error {
+	pool, err := BankQuestions(qrec.BucketID, qrec.DifficultyMin, qrec.DifficultyMax)
+	if err != nil {
+		return err
+	}
+	if len(pool) == 0 {
+		return ErrNoBankQuestions
+	}
+
+	count := qrec.QuestionCount
+	if count <= 0 {
+		count = defaultBankQuestions
+	}
+	rand.Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+	if len(pool) > count {
+		pool = pool[:count]
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, q := range pool {
+			if err := copyQuestion(tx, qrec.ID, q); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,91 @@
+// internal/quiz/citation.go
+package quiz
+
+import (
+	"time"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/utils"
+)
+
+// Citation points a question back at the passage it was written from,
+// e.g. "biology.epub, Chapter 3 > Cell Division", "lecture-5.vtt at 12:34"
+// or "textbook.pdf, p. 42–43". If the passage's file has since been
+// deleted, replaced or re-chunked, only ChunkID and SourceRemoved are set.
+type Citation struct {
+	ChunkID       uint   `json:"chunkId"`
+	ChunkIndex    int    `json:"chunkIndex"`
+	FileID        uint   `json:"fileId"`
+	Filename      string `json:"filename"`
+	HeadingPath   string `json:"headingPath,omitempty"`
+	StartMs       *int64 `json:"startMs,omitempty"`
+	EndMs         *int64 `json:"endMs,omitempty"`
+	Timestamp     string `json:"timestamp,omitempty"` // StartMs as "m:ss" / "h:mm:ss"
+	PageStart     *int   `json:"pageStart,omitempty"`
+	PageEnd       *int   `json:"pageEnd,omitempty"`
+	Pages         string `json:"pages,omitempty"` // "p. 42" / "p. 42–43"
+	SourceRemoved bool   `json:"sourceRemoved,omitempty"`
+}
+
+// loadCitations looks up the source chunks with the given IDs, keyed by
+// chunk ID. Chunks (or files) that have since been deleted get a citation
+// marked SourceRemoved, so clients can flag questions whose source is gone.
+func loadCitations(chunkIDs []uint) (map[uint]Citation, error) {
+	out := make(map[uint]Citation, len(chunkIDs))
+	if len(chunkIDs) == 0 {
+		return out, nil
+	}
+	var rows []Citation
+	if err := db.DB.Raw(`
+		SELECT
+			fc.id AS chunk_id,
+			fc.chunk_index AS chunk_index,
+			f.id AS file_id,
+			f.filename AS filename,
+			fc.heading_path AS heading_path,
+			fc.start_ms AS start_ms,
+			fc.end_ms AS end_ms,
+			fc.page_start AS page_start,
+			fc.page_end AS page_end
+		FROM file_chunks fc
+		JOIN files f ON f.id = fc.file_id AND f.deleted_at IS NULL
+		WHERE fc.id IN ? AND fc.deleted_at IS NULL
+	`, chunkIDs).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range rows {
+		if c.StartMs != nil {
+			c.Timestamp = utils.FormatTimestamp(time.Duration(*c.StartMs) * time.Millisecond)
+		}
+		c.Pages = formatPages(c.PageStart, c.PageEnd)
+		out[c.ChunkID] = c
+	}
+	for _, id := range chunkIDs {
+		if _, ok := out[id]; !ok {
+			out[id] = Citation{ChunkID: id, SourceRemoved: true}
+		}
+	}
+	return out, nil
+}
+
+// formatPages renders a chunk's page range, or "" if it has none.
+func formatPages(start, end *int) string {
+	if start == nil {
+		return ""
+	}
+	if end == nil {
+		end = start
+	}
+	return utils.FormatPages(*start, *end)
+}
+
+// citationFor returns the citation for an optional source chunk ID, or nil.
+func citationFor(citations map[uint]Citation, chunkID *uint) *Citation {
+	if chunkID == nil {
+		return nil
+	}
+	if c, ok := citations[*chunkID]; ok {
+		return &c
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+// internal/quiz/flashcards.go
+package quiz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/storage"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/utils"
+)
+
+const (
+	// flashcardChoices is the number of answer choices per flashcard question.
+	flashcardChoices = 4
+	// maxFlashcardQuestions caps a flashcard quiz when no count is given.
+	maxFlashcardQuestions = 50
+)
+
+var (
+	ErrFlashcardSource  = errors.New("flashcard source must be a CSV or XLSX file in this bucket")
+	ErrFlashcardColumns = errors.New("term or definition column not found in the spreadsheet")
+	ErrTooFewFlashcards = errors.New("need at least two rows with both a term and a definition")
+)
+
+// flashcard is one term/definition row.
+type flashcard struct {
+	Term, Definition string
+}
+
+// flashcardDeck is the usable rows of a spreadsheet, with the header names
+// used to phrase questions.
+type flashcardDeck struct {
+	TermHeader, DefinitionHeader string
+	Cards                        []flashcard
+}
+
+// FlashcardDeck reads the term and definition columns of a spreadsheet in
+// bucketID. Rows missing either value are skipped, as are repeated terms.
+func FlashcardDeck(bucketID, fileID uint, sheet, termColumn, definitionColumn string) (*flashcardDeck, error) {
+	var storageKey string
+	if err := db.DB.Table("files").
+		Select("storage_key").
+		Where("id = ? AND bucket_id = ? AND deleted_at IS NULL", fileID, bucketID).
+		Scan(&storageKey).Error; err != nil {
+		return nil, err
+	}
+	if storageKey == "" {
+		return nil, ErrFlashcardSource
+	}
+	localPath, cleanup, err := storage.LocalPath(context.Background(), storage.Store, storageKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFlashcardSource, err)
+	}
+	defer cleanup()
+	tables, err := utils.ReadTables(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFlashcardSource, err)
+	}
+
+	var t *utils.Table
+	for _, candidate := range tables {
+		if sheet == "" || strings.EqualFold(candidate.Name, sheet) {
+			t = candidate
+			break
+		}
+	}
+	if t == nil {
+		return nil, fmt.Errorf("sheet %q not found", sheet)
+	}
+	termIdx, defIdx := t.Column(termColumn), t.Column(definitionColumn)
+	if termIdx < 0 || defIdx < 0 || termIdx == defIdx {
+		return nil, ErrFlashcardColumns
+	}
+
+	deck := &flashcardDeck{TermHeader: t.Header[termIdx], DefinitionHeader: t.Header[defIdx]}
+	seen := make(map[string]bool)
+	for _, row := range t.Rows {
+		if termIdx >= len(row) || defIdx >= len(row) {
+			continue
+		}
+		term, def := row[termIdx], row[defIdx]
+		key := strings.ToLower(term)
+		if term == "" || def == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		deck.Cards = append(deck.Cards, flashcard{Term: term, Definition: def})
+	}
+	if len(deck.Cards) < 2 {
+		return nil, ErrTooFewFlashcards
+	}
+	return deck, nil
+}
+
+// generateFlashcards builds one multiple-choice question per sampled row:
+// the term is the prompt, its definition the correct answer, and other
+// rows' definitions the distractors.
+func generateFlashcards(qrec Quiz) error {
+	if qrec.SourceFileID == nil {
+		return ErrFlashcardSource
+	}
+	deck, err := FlashcardDeck(qrec.BucketID, *qrec.SourceFileID, qrec.Sheet, qrec.TermColumn, qrec.DefinitionColumn)
+	if err != nil {
+		return err
+	}
+
+	count := qrec.QuestionCount
+	if count <= 0 {
+		count = maxFlashcardQuestions
+	}
+	order := rand.Perm(len(deck.Cards))
+	if len(order) > count {
+		order = order[:count]
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, i := range order {
+			if err := insertGeneratedQuestion(tx, qrec.ID, nil, flashcardQuestion(deck, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// flashcardQuestion phrases card i of the deck as a question, drawing up to
+// flashcardChoices-1 distractors from other cards with different definitions.
+func flashcardQuestion(deck *flashcardDeck, i int) generatedQuestion {
+	card := deck.Cards[i]
+	g := generatedQuestion{
+		Question: fmt.Sprintf("%s: %s\nWhich %s goes with it?",
+			deck.TermHeader, card.Term, strings.ToLower(deck.DefinitionHeader)),
+		Explanation: fmt.Sprintf("%s — %s", card.Term, card.Definition),
+	}
+	type choice = struct {
+		Text        string `json:"text"`
+		Correct     bool   `json:"correct"`
+		Explanation string `json:"explanation"`
+	}
+	g.Choices = append(g.Choices, choice{Text: card.Definition, Correct: true, Explanation: "Correct."})
+
+	used := map[string]bool{strings.ToLower(card.Definition): true}
+	for _, j := range rand.Perm(len(deck.Cards)) {
+		if len(g.Choices) >= flashcardChoices {
+			break
+		}
+		other := deck.Cards[j]
+		if used[strings.ToLower(other.Definition)] {
+			continue
+		}
+		used[strings.ToLower(other.Definition)] = true
+		g.Choices = append(g.Choices, choice{
+			Text:        other.Definition,
+			Explanation: fmt.Sprintf("That is the %s of %q.", strings.ToLower(deck.DefinitionHeader), other.Term),
+		})
+	}
+	rand.Shuffle(len(g.Choices), func(a, b int) { g.Choices[a], g.Choices[b] = g.Choices[b], g.Choices[a] })
+	return g
+}
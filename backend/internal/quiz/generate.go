@@ -0,0 +1,140 @@
+// internal/quiz/generate.go
+package quiz
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// generatedQuestion mirrors the JSON objects ai.GenerateQuestions asks the model for.
+type generatedQuestion struct {
+	Question    string `json:"question"`
+	Explanation string `json:"explanation"`
+	Choices     []struct {
+		Text        string `json:"text"`
+		Correct     bool   `json:"correct"`
+		Explanation string `json:"explanation"`
+	} `json:"choices"`
+}
+
+// parseGeneratedQuestions extracts the JSON array from a model response,
+// tolerating code fences and chatter around it, and drops malformed
+// questions (no text, fewer than two choices, or not exactly one correct).
+func parseGeneratedQuestions(raw string) ([]generatedQuestion, error) {
+	start := strings.Index(raw, "[")
+	end := strings.LastIndex(raw, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON array in model response")
+	}
+
+	var all []generatedQuestion
+	if err := json.Unmarshal([]byte(raw[start:end+1]), &all); err != nil {
+		return nil, fmt.Errorf("could not parse generated questions: %w", err)
+	}
+
+	var out []generatedQuestion
+	for _, g := range all {
+		if strings.TrimSpace(g.Question) == "" || len(g.Choices) < 2 {
+			continue
+		}
+		correct := 0
+		for _, c := range g.Choices {
+			if c.Correct {
+				correct++
+			}
+		}
+		if correct != 1 {
+			continue
+		}
+		out = append(out, g)
+	}
+	return out, nil
+}
+
+// normalizeQuestionText lowercases and collapses whitespace/punctuation so
+// near-verbatim repeats of an existing question can be detected.
+func normalizeQuestionText(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r > 127:
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		default:
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// insertGeneratedQuestion stores one generated question and its choices in quizID.
+func insertGeneratedQuestion(tx *gorm.DB, quizID uint, sourceChunkID *uint, g generatedQuestion) error {
+	q := Question{
+		QuizID:        quizID,
+		Text:          strings.TrimSpace(g.Question),
+		Explanation:   g.Explanation,
+		SourceChunkID: sourceChunkID,
+	}
+	if err := tx.Create(&q).Error; err != nil {
+		return err
+	}
+	for _, c := range g.Choices {
+		a := Answer{
+			QuestionID:  q.ID,
+			Text:        c.Text,
+			IsCorrect:   c.Correct,
+			Explanation: c.Explanation,
+		}
+		if err := tx.Create(&a).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyQuestion duplicates q and its answers into quizID, remembering the
+// original so repeated copies all point back at the same root question.
+func copyQuestion(tx *gorm.DB, quizID uint, q Question) error {
+	origin := q.ID
+	if q.OriginQuestionID != nil {
+		origin = *q.OriginQuestionID
+	}
+
+	var answers []Answer
+	if err := tx.Where("question_id = ?", q.ID).Order("id ASC").Find(&answers).Error; err != nil {
+		return err
+	}
+
+	cp := Question{
+		QuizID:           quizID,
+		Text:             q.Text,
+		Explanation:      q.Explanation,
+		SourceChunkID:    q.SourceChunkID,
+		OriginQuestionID: &origin,
+		Difficulty:       q.Difficulty,
+		DifficultyStdErr: q.DifficultyStdErr,
+		CalibratedAt:     q.CalibratedAt,
+	}
+	if err := tx.Create(&cp).Error; err != nil {
+		return err
+	}
+	for _, a := range answers {
+		ac := Answer{
+			QuestionID:  cp.ID,
+			Text:        a.Text,
+			IsCorrect:   a.IsCorrect,
+			Explanation: a.Explanation,
+		}
+		if err := tx.Create(&ac).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
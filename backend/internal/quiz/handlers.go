@@ -3,6 +3,7 @@ package quiz
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -32,8 +33,27 @@ func ensureQueueClient() {
 }
 
 type createQuizRequest struct {
-	TimedMode    bool `json:"timedMode"`
-	PracticeMode bool `json:"practiceMode"`
+	TimedMode     bool   `json:"timedMode"`
+	PracticeMode  bool   `json:"practiceMode"`
+	Mode          string `json:"mode"`          // "standard" (default), "weak_spots", "retake_wrong", "bank", "adaptive" or "flashcards"
+	QuestionCount int    `json:"questionCount"` // optional; 0 lets the mode decide (max length for adaptive)
+
+	// Bank mode only: either a named band ("easy", "medium", "hard") or
+	// explicit bounds in logits.
+	Difficulty    string   `json:"difficulty"`
+	DifficultyMin *float64 `json:"difficultyMin"`
+	DifficultyMax *float64 `json:"difficultyMax"`
+
+	// Adaptive mode only: stop early once the ability estimate's standard
+	// error is at most this (it starts at 1).
+	TargetStdErr *float64 `json:"targetStdErr"`
+
+	// Flashcard mode only: a CSV/XLSX file in the bucket, optionally a sheet
+	// name, and the headers of the term and definition columns.
+	FileID           *uint  `json:"fileId"`
+	Sheet            string `json:"sheet"`
+	TermColumn       string `json:"termColumn"`
+	DefinitionColumn string `json:"definitionColumn"`
 }
 
 type createQuizResponse struct {
@@ -72,17 +92,109 @@ func CreateQuizHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Mode == "" {
+		req.Mode = ModeStandard
+	}
+	switch req.Mode {
+	case ModeStandard:
+	case ModeWeakSpots, ModeRetakeWrong:
+		// Review modes only make sense once the user has missed something here.
+		wrong, err := WrongQuestions(uint(bucketID), claims.UserID)
+		if err != nil {
+			http.Error(w, "could not load past answers", http.StatusInternalServerError)
+			return
+		}
+		if len(wrong) == 0 {
+			http.Error(w, ErrNoWrongAnswers.Error(), http.StatusBadRequest)
+			return
+		}
+	case ModeBank:
+		if req.Difficulty != "" {
+			min, max, ok := DifficultyBand(req.Difficulty)
+			if !ok {
+				http.Error(w, "invalid difficulty band", http.StatusBadRequest)
+				return
+			}
+			req.DifficultyMin, req.DifficultyMax = min, max
+		}
+		if req.DifficultyMin != nil && req.DifficultyMax != nil && *req.DifficultyMin > *req.DifficultyMax {
+			http.Error(w, "difficultyMin must not exceed difficultyMax", http.StatusBadRequest)
+			return
+		}
+		pool, err := BankQuestions(uint(bucketID), req.DifficultyMin, req.DifficultyMax)
+		if err != nil {
+			http.Error(w, "could not load question bank", http.StatusInternalServerError)
+			return
+		}
+		if len(pool) == 0 {
+			http.Error(w, ErrNoBankQuestions.Error(), http.StatusBadRequest)
+			return
+		}
+	case ModeAdaptive:
+		if req.TargetStdErr != nil && (*req.TargetStdErr <= 0 || *req.TargetStdErr >= 1) {
+			http.Error(w, "targetStdErr must be between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		pool, err := AdaptivePool(uint(bucketID))
+		if err != nil {
+			http.Error(w, "could not load question pool", http.StatusInternalServerError)
+			return
+		}
+		if len(pool) == 0 {
+			http.Error(w, ErrNoAdaptivePool.Error(), http.StatusBadRequest)
+			return
+		}
+	case ModeFlashcards:
+		if req.FileID == nil || req.TermColumn == "" || req.DefinitionColumn == "" {
+			http.Error(w, "fileId, termColumn and definitionColumn are required", http.StatusBadRequest)
+			return
+		}
+		if _, err := FlashcardDeck(uint(bucketID), *req.FileID, req.Sheet, req.TermColumn, req.DefinitionColumn); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "invalid quiz mode", http.StatusBadRequest)
+		return
+	}
+	if req.Mode != ModeAdaptive && req.TargetStdErr != nil {
+		http.Error(w, `targetStdErr is only supported for mode "adaptive"`, http.StatusBadRequest)
+		return
+	}
+	if req.Mode != ModeBank && (req.Difficulty != "" || req.DifficultyMin != nil || req.DifficultyMax != nil) {
+		http.Error(w, `difficulty is only supported for mode "bank"`, http.StatusBadRequest)
+		return
+	}
+	if req.Mode != ModeFlashcards && (req.FileID != nil || req.Sheet != "" || req.TermColumn != "" || req.DefinitionColumn != "") {
+		http.Error(w, `fileId and columns are only supported for mode "flashcards"`, http.StatusBadRequest)
+		return
+	}
+	if req.QuestionCount < 0 {
+		http.Error(w, "invalid question count", http.StatusBadRequest)
+		return
+	}
+
 	// 5) Create initial Quiz record with status='pending'
 	q := Quiz{
-		BucketID:     uint(bucketID),
-		Status:       "pending",
-		TimedMode:    req.TimedMode,
-		PracticeMode: req.PracticeMode,
+		BucketID:         uint(bucketID),
+		Status:           "pending",
+		Mode:             req.Mode,
+		QuestionCount:    req.QuestionCount,
+		DifficultyMin:    req.DifficultyMin,
+		DifficultyMax:    req.DifficultyMax,
+		TargetStdErr:     req.TargetStdErr,
+		SourceFileID:     req.FileID,
+		Sheet:            req.Sheet,
+		TermColumn:       req.TermColumn,
+		DefinitionColumn: req.DefinitionColumn,
+		TimedMode:        req.TimedMode,
+		PracticeMode:     req.PracticeMode,
 	}
 	if err := db.DB.Create(&q).Error; err != nil {
 		http.Error(w, "could not create quiz", http.StatusInternalServerError)
 		return
 	}
+	publishQuiz(q.ID)
 
 	// 6) Enqueue GenerateQuizTask (but first make sure queueClient is built)
 	ensureQueueClient()
@@ -145,6 +257,10 @@ func GetQuizQuestionsHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "quiz not ready", http.StatusBadRequest)
 		return
 	}
+	if qrec.Mode == ModeAdaptive {
+		http.Error(w, "adaptive quizzes serve one question at a time; start an attempt instead", http.StatusBadRequest)
+		return
+	}
 
 	// Fetch questions + answers
 	var questions []Question
@@ -160,9 +276,22 @@ func GetQuizQuestionsHandler(w http.ResponseWriter, r *http.Request) {
 		ID          uint         `json:"questionId"`
 		Text        string       `json:"text"`
 		Explanation *string      `json:"explanation,omitempty"`
+		Source      *Citation    `json:"source,omitempty"`
 		Answers     []answerResp `json:"answers"`
 	}
 
+	var chunkIDs []uint
+	for _, q := range questions {
+		if q.SourceChunkID != nil {
+			chunkIDs = append(chunkIDs, *q.SourceChunkID)
+		}
+	}
+	citations, err := loadCitations(chunkIDs)
+	if err != nil {
+		http.Error(w, "could not load question sources", http.StatusInternalServerError)
+		return
+	}
+
 	var out []questionResp
 	for _, q := range questions {
 		var ans []Answer
@@ -186,8 +315,9 @@ func GetQuizQuestionsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		qout := questionResp{
-			ID:   q.ID,
-			Text: q.Text,
+			ID:     q.ID,
+			Text:   q.Text,
+			Source: citationFor(citations, q.SourceChunkID),
 		}
 		if qrec.PracticeMode {
 			qout.Explanation = &q.Explanation
@@ -203,8 +333,9 @@ func GetQuizQuestionsHandler(w http.ResponseWriter, r *http.Request) {
 // POST /quizzes/{quizId}/attempts
 type submitAnswersReq struct {
 	Answers []struct {
-		QuestionID uint `json:"questionId"`
-		AnswerID   uint `json:"answerId"`
+		QuestionID  uint `json:"questionId"`
+		AnswerID    uint `json:"answerId"`
+		TimeSpentMs *int `json:"timeSpentMs,omitempty"`
 	} `json:"answers"`
 }
 
@@ -237,6 +368,25 @@ func SubmitQuizHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Adaptive quizzes: this starts an attempt; questions are then fetched
+	// one at a time from GET /attempts/{attemptId}/next.
+	if qrec.Mode == ModeAdaptive {
+		att, err := StartAdaptiveAttempt(qrec, claims.UserID)
+		if err != nil {
+			http.Error(w, "could not create attempt", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"attemptId": att.ID,
+			"status":    att.Status,
+			"ability":   att.Ability,
+			"stdErr":    att.AbilityStdErr,
+		})
+		return
+	}
+
 	var payload submitAnswersReq
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		http.Error(w, "invalid payload", http.StatusBadRequest)
@@ -266,10 +416,11 @@ func SubmitQuizHandler(w http.ResponseWriter, r *http.Request) {
 			correctCount++
 		}
 		aa := AttemptAnswer{
-			AttemptID:  att.ID,
-			QuestionID: ans.QuestionID,
-			AnswerID:   ans.AnswerID,
-			IsCorrect:  isCorr,
+			AttemptID:   att.ID,
+			QuestionID:  ans.QuestionID,
+			AnswerID:    ans.AnswerID,
+			IsCorrect:   isCorr,
+			TimeSpentMs: ans.TimeSpentMs,
 		}
 		db.DB.Create(&aa)
 	}
@@ -286,6 +437,44 @@ func SubmitQuizHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(submitAnswersResp{AttemptID: att.ID, Score: score})
 }
 
+// GET /quizzes/{quizId}/analytics
+// Only the owner of the quiz's bucket may see its analytics.
+func GetQuizAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	quizID, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid quiz ID", http.StatusBadRequest)
+		return
+	}
+
+	var qrec Quiz
+	if err := db.DB.First(&qrec, quizID).Error; err != nil {
+		http.Error(w, "quiz not found", http.StatusNotFound)
+		return
+	}
+
+	var b bucket.Bucket
+	if err := db.DB.First(&b, qrec.BucketID).Error; err != nil || b.UserID != claims.UserID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	analytics, err := LoadQuizAnalytics(qrec.ID)
+	if err != nil {
+		http.Error(w, "could not compute analytics", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analytics)
+}
+
 // GET /buckets/{bucketId}/attempts
 func ListAttemptsHandler(w http.ResponseWriter, r *http.Request) {
 	claims, ok := auth.FromContext(r.Context())
@@ -301,7 +490,8 @@ func ListAttemptsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Join quizzes → attempts to filter for this bucket
+	// Join quizzes → attempts to filter for this bucket; adaptive attempts
+	// still in progress have no final score yet.
 	type row struct {
 		AttemptID uint      `json:"attemptId"`
 		QuizID    uint      `json:"quizId"`
@@ -312,13 +502,33 @@ func ListAttemptsHandler(w http.ResponseWriter, r *http.Request) {
 	db.DB.Table("attempts").
 		Select("attempts.id AS attempt_id, attempts.quiz_id, attempts.score, attempts.created_at").
 		Joins("JOIN quizzes ON quizzes.id = attempts.quiz_id").
-		Where("quizzes.bucket_id = ? AND attempts.user_id = ?", bucketID, claims.UserID).
+		Where("quizzes.bucket_id = ? AND attempts.user_id = ? AND attempts.status = ?",
+			bucketID, claims.UserID, AttemptCompleted).
 		Scan(&results)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(results)
 }
 
+// GET /me/progress
+// Aggregates the caller's attempts into mastery, trends, streak and weak spots.
+func GetProgressHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	progress, err := LoadProgress(claims.UserID, time.Now())
+	if err != nil {
+		http.Error(w, "could not compute progress", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
+
 // GET /attempts/{attemptId}
 func GetAttemptDetailsHandler(w http.ResponseWriter, r *http.Request) {
 	claims, ok := auth.FromContext(r.Context())
@@ -345,12 +555,14 @@ func GetAttemptDetailsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type detailRow struct {
-		QuestionText       string `json:"questionText"`
-		SelectedAnswerID   uint   `json:"selectedAnswerId"`
-		SelectedAnswerText string `json:"selectedAnswerText"`
-		IsCorrect          bool   `json:"isCorrect"`
-		CorrectAnswerText  string `json:"correctAnswerText"`
-		Explanation        string `json:"explanation"`
+		QuestionText       string    `json:"questionText"`
+		SelectedAnswerID   uint      `json:"selectedAnswerId"`
+		SelectedAnswerText string    `json:"selectedAnswerText"`
+		IsCorrect          bool      `json:"isCorrect"`
+		CorrectAnswerText  string    `json:"correctAnswerText"`
+		Explanation        string    `json:"explanation"`
+		SourceChunkID      *uint     `json:"-"`
+		Source             *Citation `json:"source,omitempty"`
 	}
 	var details []detailRow
 	db.DB.Raw(`
@@ -360,19 +572,178 @@ func GetAttemptDetailsHandler(w http.ResponseWriter, r *http.Request) {
 			a.text AS selected_answer_text,
 			aa.is_correct AS is_correct,
 			(SELECT text FROM answers WHERE question_id = q.id AND is_correct = true LIMIT 1) AS correct_answer_text,
-			q.explanation AS explanation
+			q.explanation AS explanation,
+			q.source_chunk_id AS source_chunk_id
 		FROM attempt_answers aa
 		JOIN questions q ON q.id = aa.question_id
 		JOIN answers a ON a.id = aa.answer_id
 		WHERE aa.attempt_id = ?
 	`, attemptID).Scan(&details)
 
+	var chunkIDs []uint
+	for _, d := range details {
+		if d.SourceChunkID != nil {
+			chunkIDs = append(chunkIDs, *d.SourceChunkID)
+		}
+	}
+	citations, err := loadCitations(chunkIDs)
+	if err != nil {
+		http.Error(w, "could not load question sources", http.StatusInternalServerError)
+		return
+	}
+	for i := range details {
+		details[i].Source = citationFor(citations, details[i].SourceChunkID)
+	}
+
 	resp := map[string]interface{}{
 		"attemptId": att.ID,
 		"quizId":    att.QuizID,
 		"score":     att.Score,
+		"status":    att.Status,
 		"details":   details,
 	}
+	if att.Ability != nil {
+		resp["ability"] = att.Ability
+		resp["stdErr"] = att.AbilityStdErr
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// loadOwnAdaptiveAttempt parses /attempts/{attemptId}/..., and loads the
+// attempt and its quiz, writing an error response if anything is off.
+func loadOwnAdaptiveAttempt(w http.ResponseWriter, r *http.Request) (*Attempt, *Quiz, bool) {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	parts := strings.Split(r.URL.Path, "/")
+	attemptID, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid attempt ID", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	var att Attempt
+	if err := db.DB.First(&att, attemptID).Error; err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	if att.UserID != claims.UserID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return nil, nil, false
+	}
+
+	var qrec Quiz
+	if err := db.DB.First(&qrec, att.QuizID).Error; err != nil {
+		http.Error(w, "quiz not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	if qrec.Mode != ModeAdaptive {
+		http.Error(w, "not an adaptive attempt", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	return &att, &qrec, true
+}
+
+// GET /attempts/{attemptId}/next
+// Serves the next adaptive question, or reports the final ability estimate
+// once the quiz's stopping rule is met.
+func GetNextQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	att, qrec, ok := loadOwnAdaptiveAttempt(w, r)
+	if !ok {
+		return
+	}
+
+	q, err := NextAdaptiveQuestion(att, *qrec)
+	if err != nil {
+		http.Error(w, "could not select next question", http.StatusInternalServerError)
+		return
+	}
+
+	var answered int64
+	db.DB.Model(&AttemptAnswer{}).Where("attempt_id = ?", att.ID).Count(&answered)
+
+	resp := map[string]interface{}{
+		"attemptId": att.ID,
+		"status":    att.Status,
+		"done":      q == nil,
+		"answered":  answered,
+		"ability":   att.Ability,
+		"stdErr":    att.AbilityStdErr,
+	}
+	if q != nil {
+		var ans []Answer
+		db.DB.Where("question_id = ?", q.ID).Order("id ASC").Find(&ans)
+
+		type answerResp struct {
+			ID   uint   `json:"id"`
+			Text string `json:"text"`
+		}
+		aresp := make([]answerResp, 0, len(ans))
+		for _, a := range ans {
+			aresp = append(aresp, answerResp{ID: a.ID, Text: a.Text})
+		}
+		resp["question"] = map[string]interface{}{
+			"questionId": q.ID,
+			"text":       q.Text,
+			"answers":    aresp,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /attempts/{attemptId}/answers
+type adaptiveAnswerReq struct {
+	QuestionID  uint `json:"questionId"`
+	AnswerID    uint `json:"answerId"`
+	TimeSpentMs *int `json:"timeSpentMs,omitempty"`
+}
+
+func SubmitAdaptiveAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	att, qrec, ok := loadOwnAdaptiveAttempt(w, r)
+	if !ok {
+		return
+	}
+
+	var req adaptiveAnswerReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	isCorrect, err := AnswerAdaptiveQuestion(att, *qrec, req.QuestionID, req.AnswerID, req.TimeSpentMs)
+	switch {
+	case errors.Is(err, ErrAttemptCompleted) || errors.Is(err, ErrAlreadyAnswered):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, ErrNotCurrentQuestion) || errors.Is(err, ErrInvalidAnswer):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, "could not record answer", http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"attemptId": att.ID,
+		"isCorrect": isCorrect,
+		"status":    att.Status,
+		"done":      att.Status == AttemptCompleted,
+		"ability":   att.Ability,
+		"stdErr":    att.AbilityStdErr,
+	}
+	if qrec.PracticeMode {
+		var q Question
+		if err := db.DB.First(&q, req.QuestionID).Error; err == nil {
+			resp["explanation"] = q.Explanation
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
@@ -0,0 +1,215 @@
+// internal/quiz/irt.go
+package quiz
+
+import "math"
+
+// The Rasch (1PL) model says the probability that a learner with ability θ
+// answers an item of difficulty b correctly is 1 / (1 + e^-(θ-b)). Both are
+// on the same logit scale: an item with b = 0 is answered correctly half the
+// time by an average learner.
+
+// raschPriorVar is the variance of the N(0, σ²) prior placed on every
+// ability and difficulty. It keeps estimates finite for learners who got
+// everything right (or wrong) and for items nobody missed, which plain joint
+// maximum likelihood would push to ±∞.
+const raschPriorVar = 4.0
+
+// raschMaxStep caps a single Newton step so early iterations can't overshoot.
+const raschMaxStep = 1.0
+
+// irtResponse is one scored answer: person answered item correctly or not.
+type irtResponse struct {
+	Person  uint
+	Item    uint
+	Correct bool
+}
+
+// raschEstimate is a point estimate with its standard error and the number
+// of responses it is based on.
+type raschEstimate struct {
+	Value  float64
+	StdErr float64
+	N      int
+}
+
+// raschFit is the result of fitRasch.
+type raschFit struct {
+	Abilities    map[uint]raschEstimate
+	Difficulties map[uint]raschEstimate
+	Iterations   int
+	Converged    bool
+}
+
+// raschProb is the model probability of a correct answer.
+func raschProb(theta, b float64) float64 {
+	return 1 / (1 + math.Exp(-(theta - b)))
+}
+
+// clampStep limits a Newton update to ±raschMaxStep.
+func clampStep(d float64) float64 {
+	return math.Max(-raschMaxStep, math.Min(raschMaxStep, d))
+}
+
+// fitRasch estimates abilities and difficulties by penalized joint maximum
+// likelihood, alternating one Newton step for every person and every item
+// until a pass moves no parameter by tol or more (or maxIter is reached).
+// Difficulties are centred on 0 after each pass to fix the scale.
+func fitRasch(responses []irtResponse, maxIter int, tol float64) raschFit {
+	fit := raschFit{
+		Abilities:    make(map[uint]raschEstimate),
+		Difficulties: make(map[uint]raschEstimate),
+	}
+	if len(responses) == 0 {
+		return fit
+	}
+
+	theta := make(map[uint]float64)
+	b := make(map[uint]float64)
+	byPerson := make(map[uint][]irtResponse)
+	byItem := make(map[uint][]irtResponse)
+	for _, r := range responses {
+		byPerson[r.Person] = append(byPerson[r.Person], r)
+		byItem[r.Item] = append(byItem[r.Item], r)
+	}
+
+	// Start items at the logit of their (smoothed) proportion wrong.
+	for item, rs := range byItem {
+		correct := 0
+		for _, r := range rs {
+			if r.Correct {
+				correct++
+			}
+		}
+		b[item] = math.Log((float64(len(rs)-correct) + 0.5) / (float64(correct) + 0.5))
+	}
+	for person := range byPerson {
+		theta[person] = 0
+	}
+
+	prevTheta := make(map[uint]float64, len(theta))
+	prevB := make(map[uint]float64, len(b))
+	for fit.Iterations = 1; fit.Iterations <= maxIter; fit.Iterations++ {
+		for person, v := range theta {
+			prevTheta[person] = v
+		}
+		for item, v := range b {
+			prevB[item] = v
+		}
+
+		for person, rs := range byPerson {
+			grad, info := -theta[person]/raschPriorVar, 1/raschPriorVar
+			for _, r := range rs {
+				p := raschProb(theta[person], b[r.Item])
+				if r.Correct {
+					grad += 1 - p
+				} else {
+					grad -= p
+				}
+				info += p * (1 - p)
+			}
+			theta[person] += clampStep(grad / info)
+		}
+
+		for item, rs := range byItem {
+			grad, info := -b[item]/raschPriorVar, 1/raschPriorVar
+			for _, r := range rs {
+				p := raschProb(theta[r.Person], b[item])
+				// d/db of the log-likelihood is −(x − p).
+				if r.Correct {
+					grad -= 1 - p
+				} else {
+					grad += p
+				}
+				info += p * (1 - p)
+			}
+			b[item] += clampStep(grad / info)
+		}
+
+		// Centre difficulties; shift abilities by the same amount so the
+		// predicted probabilities are unchanged.
+		var mean float64
+		for _, v := range b {
+			mean += v
+		}
+		mean /= float64(len(b))
+		for item := range b {
+			b[item] -= mean
+		}
+		for person := range theta {
+			theta[person] -= mean
+		}
+
+		// Converged once a whole pass, centring included, moves nothing by
+		// tol or more. (The Newton steps alone never vanish: the prior pulls
+		// every parameter towards 0 and centring pushes them back.)
+		maxChange := 0.0
+		for person, v := range theta {
+			maxChange = math.Max(maxChange, math.Abs(v-prevTheta[person]))
+		}
+		for item, v := range b {
+			maxChange = math.Max(maxChange, math.Abs(v-prevB[item]))
+		}
+		if maxChange < tol {
+			fit.Converged = true
+			break
+		}
+	}
+	if fit.Iterations > maxIter {
+		fit.Iterations = maxIter
+	}
+
+	for person, rs := range byPerson {
+		info := 1 / raschPriorVar
+		for _, r := range rs {
+			p := raschProb(theta[person], b[r.Item])
+			info += p * (1 - p)
+		}
+		fit.Abilities[person] = raschEstimate{Value: theta[person], StdErr: 1 / math.Sqrt(info), N: len(rs)}
+	}
+	for item, rs := range byItem {
+		info := 1 / raschPriorVar
+		for _, r := range rs {
+			p := raschProb(theta[r.Person], b[item])
+			info += p * (1 - p)
+		}
+		fit.Difficulties[item] = raschEstimate{Value: b[item], StdErr: 1 / math.Sqrt(info), N: len(rs)}
+	}
+	return fit
+}
+
+// scoredItem is one answered item with a known (or assumed) difficulty.
+type scoredItem struct {
+	Difficulty float64
+	Correct    bool
+}
+
+// estimateAbility returns the maximum a posteriori ability for a single
+// learner given items of known difficulty, under a N(priorMean, priorVar)
+// prior, along with its standard error. With no items it returns the prior.
+func estimateAbility(items []scoredItem, priorMean, priorVar float64) (theta, stdErr float64) {
+	theta = priorMean
+	for iter := 0; iter < 50; iter++ {
+		grad, info := -(theta-priorMean)/priorVar, 1/priorVar
+		for _, it := range items {
+			p := raschProb(theta, it.Difficulty)
+			if it.Correct {
+				grad += 1 - p
+			} else {
+				grad -= p
+			}
+			info += p * (1 - p)
+		}
+		step := clampStep(grad / info)
+		theta += step
+		if math.Abs(step) < 1e-6 {
+			break
+		}
+	}
+
+	info := 1 / priorVar
+	for _, it := range items {
+		p := raschProb(theta, it.Difficulty)
+		info += p * (1 - p)
+	}
+	return theta, 1 / math.Sqrt(info)
+}
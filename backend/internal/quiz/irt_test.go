@@ -0,0 +1,173 @@
+package quiz
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// responsesFrom turns a person × item matrix into responses; persons and
+// items are numbered from 1.
+func responsesFrom(m [][]bool) []irtResponse {
+	var rs []irtResponse
+	for p, row := range m {
+		for i, correct := range row {
+			rs = append(rs, irtResponse{Person: uint(p + 1), Item: uint(i + 1), Correct: correct})
+		}
+	}
+	return rs
+}
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-3 }
+
+func TestFitRaschConverges(t *testing.T) {
+	// Item 1 is answered by everyone, item 4 by one person; items 2 and 3
+	// by three each. Persons 2 and 5 have the same raw score.
+	responses := responsesFrom([][]bool{
+		{true, true, true, false},
+		{true, true, false, false},
+		{true, false, false, false},
+		{true, true, true, true},
+		{true, false, true, false},
+	})
+	fit := fitRasch(responses, calibrationMaxIter, calibrationTol)
+
+	if !fit.Converged || fit.Iterations >= calibrationMaxIter {
+		t.Fatalf("fit did not converge: %d iterations", fit.Iterations)
+	}
+	b := func(item uint) float64 { return fit.Difficulties[item].Value }
+	theta := func(person uint) float64 { return fit.Abilities[person].Value }
+
+	var sum float64
+	for _, est := range fit.Difficulties {
+		sum += est.Value
+	}
+	if !near(sum, 0) {
+		t.Errorf("difficulties sum to %v, want 0", sum)
+	}
+	if !(b(1) < b(2) && b(2) < b(4)) {
+		t.Errorf("difficulties %v, %v, %v are not ordered by proportion wrong", b(1), b(2), b(4))
+	}
+	// Raw scores are sufficient statistics in the Rasch model.
+	if !near(b(2), b(3)) {
+		t.Errorf("items 2 and 3 have the same score but difficulties %v and %v", b(2), b(3))
+	}
+	if !near(theta(2), theta(5)) {
+		t.Errorf("persons 2 and 5 have the same score but abilities %v and %v", theta(2), theta(5))
+	}
+	if !(theta(3) < theta(2) && theta(2) < theta(1) && theta(1) < theta(4)) {
+		t.Errorf("abilities %v, %v, %v, %v are not ordered by score", theta(3), theta(2), theta(1), theta(4))
+	}
+	if est := fit.Difficulties[1]; est.N != 5 || est.StdErr <= 0 {
+		t.Errorf("item 1 estimate = %+v, want N 5 and a positive standard error", est)
+	}
+
+	// A much tighter tolerance lands on the same estimates.
+	tight := fitRasch(responses, 1000, 1e-9)
+	for item, est := range tight.Difficulties {
+		if !near(est.Value, b(item)) {
+			t.Errorf("item %d: %v at calibrationTol, %v at 1e-9", item, b(item), est.Value)
+		}
+	}
+}
+
+func TestFitRaschStaysFinite(t *testing.T) {
+	tests := []struct {
+		name string
+		m    [][]bool
+	}{
+		{"item nobody missed", [][]bool{{true, true}, {true, false}, {true, false}}},
+		{"item nobody got right", [][]bool{{false, true}, {false, false}, {false, true}}},
+		{"learner who got everything right", [][]bool{{true, true}, {false, true}, {false, false}}},
+		{"everyone right on everything", [][]bool{{true, true}, {true, true}}},
+		{"everyone wrong on everything", [][]bool{{false, false}, {false, false}}},
+		{"single response", [][]bool{{true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fit := fitRasch(responsesFrom(tt.m), calibrationMaxIter, calibrationTol)
+			if !fit.Converged {
+				t.Errorf("did not converge in %d iterations", fit.Iterations)
+			}
+			for item, est := range fit.Difficulties {
+				if math.IsNaN(est.Value) || math.Abs(est.Value) > 10 || !(est.StdErr > 0) {
+					t.Errorf("item %d: %+v", item, est)
+				}
+			}
+			for person, est := range fit.Abilities {
+				if math.IsNaN(est.Value) || math.Abs(est.Value) > 10 || !(est.StdErr > 0) {
+					t.Errorf("person %d: %+v", person, est)
+				}
+			}
+		})
+	}
+}
+
+func TestFitRaschExtremeItemsOrder(t *testing.T) {
+	fit := fitRasch(responsesFrom([][]bool{
+		{true, true, false},
+		{true, false, false},
+		{true, true, false},
+	}), calibrationMaxIter, calibrationTol)
+	easy, mid, hard := fit.Difficulties[1].Value, fit.Difficulties[2].Value, fit.Difficulties[3].Value
+	if !(easy < mid && mid < hard) {
+		t.Errorf("difficulties %v, %v, %v: want all-correct < mixed < all-wrong", easy, mid, hard)
+	}
+}
+
+func TestFitRaschNoResponses(t *testing.T) {
+	fit := fitRasch(nil, calibrationMaxIter, calibrationTol)
+	if len(fit.Abilities) != 0 || len(fit.Difficulties) != 0 {
+		t.Errorf("fitRasch(nil) = %+v", fit)
+	}
+}
+
+func TestDifficultyUpdates(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		n          int
+		calibrated bool
+	}{
+		{1, false},
+		{minCalibrationResponses - 1, false},
+		{minCalibrationResponses, true},
+		{minCalibrationResponses + 20, true},
+	}
+	for _, tt := range tests {
+		updates := difficultyUpdates(raschEstimate{Value: 0.7, StdErr: 0.4, N: tt.n}, now)
+		if updates["difficulty_responses"] != tt.n {
+			t.Errorf("N=%d: difficulty_responses = %v", tt.n, updates["difficulty_responses"])
+		}
+		_, has := updates["difficulty"]
+		if has != tt.calibrated {
+			t.Errorf("N=%d: stores difficulty = %v, want %v", tt.n, has, tt.calibrated)
+		}
+		if tt.calibrated && (updates["difficulty"] != 0.7 || updates["difficulty_std_err"] != 0.4 || updates["calibrated_at"] != now) {
+			t.Errorf("N=%d: updates = %v", tt.n, updates)
+		}
+	}
+}
+
+func TestEstimateAbility(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []scoredItem
+		check func(theta float64) bool
+	}{
+		{"no items returns the prior mean", nil, func(theta float64) bool { return theta == 0.5 }},
+		{"all correct stays finite above the prior", []scoredItem{{0, true}, {1, true}, {2, true}}, func(theta float64) bool { return theta > 0.5 && theta < 10 }},
+		{"all wrong stays finite below the prior", []scoredItem{{0, false}, {-1, false}}, func(theta float64) bool { return theta < 0.5 && theta > -10 }},
+		{"right on easy, wrong on hard lands between", []scoredItem{{-2, true}, {2, false}}, func(theta float64) bool { return theta > -2 && theta < 2 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			theta, stdErr := estimateAbility(tt.items, 0.5, adaptivePriorVar)
+			if !tt.check(theta) {
+				t.Errorf("theta = %v", theta)
+			}
+			if !(stdErr > 0 && stdErr <= math.Sqrt(adaptivePriorVar)) {
+				t.Errorf("stdErr = %v, want in (0, prior sd]", stdErr)
+			}
+		})
+	}
+}
@@ -7,10 +7,38 @@ import (
   "gorm.io/gorm"
 )
 
+// Quiz modes decide how GenerateQuiz fills a quiz with questions.
+const (
+  ModeStandard    = "standard"     // generate from the bucket's material
+  ModeWeakSpots   = "weak_spots"   // generate new questions on concepts the user got wrong
+  ModeRetakeWrong = "retake_wrong" // copy the questions the user got wrong
+  ModeBank        = "bank"         // assemble from calibrated questions in a difficulty band
+  ModeAdaptive    = "adaptive"     // serve questions one at a time based on the learner's answers
+  ModeFlashcards  = "flashcards"   // term/definition questions built from two spreadsheet columns, no model call
+)
+
+// Attempt statuses. Regular attempts are created "completed"; adaptive
+// attempts stay "in_progress" until their stopping rule is met.
+const (
+  AttemptInProgress = "in_progress"
+  AttemptCompleted  = "completed"
+)
+
 type Quiz struct {
   ID           uint           `gorm:"primaryKey"`
   BucketID     uint           `gorm:"index;not null"`
   Status       string         `gorm:"size:20;not null"` // 'pending','generating','ready','failed'
+  Mode         string         `gorm:"size:20;not null;default:'standard'"`
+  QuestionCount int           `gorm:"not null;default:0"` // 0 = mode default
+  DifficultyMin *float64     // bank mode: lowest calibrated difficulty (logits), nil = open
+  DifficultyMax *float64     // bank mode: highest calibrated difficulty (logits), nil = open
+  TargetStdErr  *float64     // adaptive mode: stop once the ability SE drops to this, nil = fixed length only
+  // Flashcard mode: the spreadsheet file, its sheet ("" = first) and the
+  // header names of the term and definition columns.
+  SourceFileID     *uint
+  Sheet            string     `gorm:"size:255"`
+  TermColumn       string     `gorm:"size:255"`
+  DefinitionColumn string     `gorm:"size:255"`
   TimedMode    bool           `gorm:"not null"`
   PracticeMode bool           `gorm:"not null"`
   ErrorMsg     *string        `gorm:"type:text"`
@@ -24,6 +52,19 @@ type Question struct {
   QuizID    uint           `gorm:"index;not null"`
   Text      string         `gorm:"type:text;not null"`
   Explanation string       `gorm:"type:text"`
+  // SourceChunkID points at the file_chunks row the question was generated
+  // from, so progress and citations can link back to the material.
+  SourceChunkID *uint      `gorm:"index"`
+  // OriginQuestionID is set when the question was copied from an earlier
+  // quiz (e.g. a "retake wrong" quiz), and points at the original.
+  OriginQuestionID *uint   `gorm:"index"`
+  // Difficulty is the Rasch difficulty (logits) fitted by CalibrateItems
+  // from real answers; nil until enough responses exist. Copies share the
+  // difficulty of their origin question.
+  Difficulty          *float64   `gorm:"index"`
+  DifficultyStdErr    *float64
+  DifficultyResponses int        `gorm:"not null;default:0"`
+  CalibratedAt        *time.Time
   CreatedAt time.Time
   UpdatedAt time.Time
   DeletedAt gorm.DeletedAt `gorm:"index"`
@@ -45,6 +86,12 @@ type Attempt struct {
   QuizID    uint           `gorm:"index;not null"`
   UserID    uint           `gorm:"index;not null"`
   Score     float64        `gorm:"not null"`
+  Status    string         `gorm:"size:20;not null;default:'completed'"` // 'in_progress','completed'
+  // Adaptive attempts only: the current ability estimate (logits), its
+  // standard error, and the question served but not yet answered.
+  Ability           *float64
+  AbilityStdErr     *float64
+  CurrentQuestionID *uint
   CreatedAt time.Time
   UpdatedAt time.Time
   DeletedAt gorm.DeletedAt `gorm:"index"`
@@ -56,8 +103,20 @@ type AttemptAnswer struct {
   QuestionID  uint           `gorm:"index;not null"`
   AnswerID    uint           `gorm:"index;not null"`
   IsCorrect   bool           `gorm:"not null"`
+  TimeSpentMs *int           // nullable; only set when the client reports it
   CreatedAt   time.Time
   UpdatedAt   time.Time
   DeletedAt   gorm.DeletedAt `gorm:"index"`
 }
 
+// LearnerAbility is the Rasch ability (logits) CalibrateItems estimated for
+// a user from all of their answers.
+type LearnerAbility struct {
+  ID        uint      `gorm:"primaryKey"`
+  UserID    uint      `gorm:"uniqueIndex;not null"`
+  Ability   float64   `gorm:"not null"`
+  StdErr    float64   `gorm:"not null"`
+  Responses int       `gorm:"not null"`
+  CreatedAt time.Time
+  UpdatedAt time.Time
+}
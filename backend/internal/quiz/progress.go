@@ -0,0 +1,394 @@
+// internal/quiz/progress.go
+package quiz
+
+import (
+	"math"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+)
+
+// masteryHalfLife controls how quickly old answers stop counting towards
+// mastery: an answer this old weighs half as much as one given today.
+const masteryHalfLife = 30 * 24 * time.Hour
+
+// weakConceptLimit is how many weakest concepts GET /me/progress returns.
+const weakConceptLimit = 5
+
+// snippetRunes is the length of the chunk preview attached to a weak concept.
+const snippetRunes = 200
+
+// Mastery is a recency-weighted, smoothed estimate of how often the learner
+// answers questions in some group correctly. Estimate is in [0, 1].
+type Mastery struct {
+	Estimate float64 `json:"estimate"`
+	Answered int     `json:"answered"`
+	Correct  int     `json:"correct"`
+	LastSeen string  `json:"lastSeen"`
+}
+
+// TrendPoint is the average attempt score on one calendar day (UTC).
+type TrendPoint struct {
+	Date     string  `json:"date"`
+	Attempts int     `json:"attempts"`
+	AvgScore float64 `json:"avgScore"`
+}
+
+// TopicProgress is mastery over the questions drawn from one source file.
+type TopicProgress struct {
+	FileID   uint    `json:"fileId"`
+	Filename string  `json:"filename"`
+	Mastery  Mastery `json:"mastery"`
+}
+
+// BucketProgress groups mastery, topics and trend for one bucket.
+type BucketProgress struct {
+	BucketID   uint            `json:"bucketId"`
+	BucketName string          `json:"bucketName"`
+	Mastery    Mastery         `json:"mastery"`
+	Topics     []TopicProgress `json:"topics"`
+	Trend      []TrendPoint    `json:"trend"`
+}
+
+// WeakConcept is a source chunk whose questions the learner keeps missing.
+type WeakConcept struct {
+	ChunkID     uint    `json:"chunkId"`
+	ChunkIndex  int     `json:"chunkIndex"`
+	FileID      uint    `json:"fileId"`
+	Filename    string  `json:"filename"`
+	HeadingPath string  `json:"headingPath,omitempty"`
+	Pages       string  `json:"pages,omitempty"` // e.g. "p. 42–43" for PDF chunks
+	BucketID    uint    `json:"bucketId"`
+	Snippet     string  `json:"snippet"`
+	Mastery     Mastery `json:"mastery"`
+}
+
+// Streak counts consecutive days (UTC) with at least one attempt.
+// Current is still alive if the last attempt was today or yesterday.
+type Streak struct {
+	Current        int    `json:"current"`
+	Longest        int    `json:"longest"`
+	LastActiveDate string `json:"lastActiveDate,omitempty"`
+}
+
+// Progress is the payload returned by GET /me/progress.
+type Progress struct {
+	Overall      Mastery          `json:"overall"`
+	Buckets      []BucketProgress `json:"buckets"`
+	Trend        []TrendPoint     `json:"trend"`
+	Streak       Streak           `json:"streak"`
+	WeakConcepts []WeakConcept    `json:"weakConcepts"`
+}
+
+// progressAttempt is one of the caller's attempts, joined to its bucket.
+type progressAttempt struct {
+	AttemptID  uint
+	BucketID   uint
+	BucketName string
+	Score      float64
+	CreatedAt  time.Time
+}
+
+// progressAnswer is one answered question, joined to its source chunk/file
+// when the question records one.
+type progressAnswer struct {
+	BucketID    uint
+	IsCorrect   bool
+	CreatedAt   time.Time
+	ChunkID     *uint
+	ChunkIndex  *int
+	HeadingPath *string
+	PageStart   *int
+	PageEnd     *int
+	FileID      *uint
+	Filename    *string
+}
+
+// LoadProgress aggregates every attempt made by userID into a Progress.
+func LoadProgress(userID uint, now time.Time) (Progress, error) {
+	var attempts []progressAttempt
+	if err := db.DB.Raw(`
+		SELECT
+			at.id AS attempt_id,
+			b.id AS bucket_id,
+			b.name AS bucket_name,
+			at.score AS score,
+			at.created_at AS created_at
+		FROM attempts at
+		JOIN quizzes qz ON qz.id = at.quiz_id
+		JOIN buckets b ON b.id = qz.bucket_id
+		WHERE at.user_id = ? AND at.status = ? AND at.deleted_at IS NULL
+		ORDER BY at.created_at ASC
+	`, userID, AttemptCompleted).Scan(&attempts).Error; err != nil {
+		return Progress{}, err
+	}
+
+	var answers []progressAnswer
+	if err := db.DB.Raw(`
+		SELECT
+			qz.bucket_id AS bucket_id,
+			aa.is_correct AS is_correct,
+			aa.created_at AS created_at,
+			fc.id AS chunk_id,
+			fc.chunk_index AS chunk_index,
+			fc.heading_path AS heading_path,
+			fc.page_start AS page_start,
+			fc.page_end AS page_end,
+			f.id AS file_id,
+			f.filename AS filename
+		FROM attempt_answers aa
+		JOIN attempts at ON at.id = aa.attempt_id
+		JOIN quizzes qz ON qz.id = at.quiz_id
+		JOIN questions q ON q.id = aa.question_id
+		LEFT JOIN file_chunks fc ON fc.id = q.source_chunk_id AND fc.deleted_at IS NULL
+		LEFT JOIN files f ON f.id = fc.file_id AND f.deleted_at IS NULL
+		WHERE at.user_id = ? AND at.status = ?
+			AND at.deleted_at IS NULL AND aa.deleted_at IS NULL
+	`, userID, AttemptCompleted).Scan(&answers).Error; err != nil {
+		return Progress{}, err
+	}
+
+	p := computeProgress(attempts, answers, now)
+
+	// Attach a preview of each weak concept's text.
+	if len(p.WeakConcepts) > 0 {
+		ids := make([]uint, 0, len(p.WeakConcepts))
+		for _, c := range p.WeakConcepts {
+			ids = append(ids, c.ChunkID)
+		}
+		var rows []struct {
+			ID      uint
+			Content string
+		}
+		if err := db.DB.Table("file_chunks").Select("id, content").Where("id IN ?", ids).Scan(&rows).Error; err != nil {
+			return Progress{}, err
+		}
+		content := make(map[uint]string, len(rows))
+		for _, r := range rows {
+			content[r.ID] = r.Content
+		}
+		for i := range p.WeakConcepts {
+			p.WeakConcepts[i].Snippet = snippet(content[p.WeakConcepts[i].ChunkID], snippetRunes)
+		}
+	}
+	return p, nil
+}
+
+// masteryAcc accumulates weighted answers for one group.
+type masteryAcc struct {
+	weighted, weightedCorrect float64
+	answered, correct         int
+	lastSeen                  time.Time
+}
+
+func (m *masteryAcc) add(isCorrect bool, at, now time.Time) {
+	age := now.Sub(at)
+	if age < 0 {
+		age = 0
+	}
+	w := math.Pow(0.5, float64(age)/float64(masteryHalfLife))
+	m.weighted += w
+	m.answered++
+	if isCorrect {
+		m.weightedCorrect += w
+		m.correct++
+	}
+	if at.After(m.lastSeen) {
+		m.lastSeen = at
+	}
+}
+
+// mastery applies Laplace smoothing so a single answer doesn't swing the
+// estimate to 0 or 1.
+func (m *masteryAcc) mastery() Mastery {
+	out := Mastery{
+		Estimate: (m.weightedCorrect + 1) / (m.weighted + 2),
+		Answered: m.answered,
+		Correct:  m.correct,
+	}
+	if !m.lastSeen.IsZero() {
+		out.LastSeen = m.lastSeen.UTC().Format(time.RFC3339)
+	}
+	return out
+}
+
+// computeProgress is the database-free part of LoadProgress.
+func computeProgress(attempts []progressAttempt, answers []progressAnswer, now time.Time) Progress {
+	var overall masteryAcc
+	bucketAcc := make(map[uint]*masteryAcc)
+	topicAcc := make(map[uint]map[uint]*masteryAcc) // bucket → file → acc
+	topicNames := make(map[uint]string)
+	chunkAcc := make(map[uint]*masteryAcc)
+	chunkInfo := make(map[uint]WeakConcept)
+
+	for _, a := range answers {
+		overall.add(a.IsCorrect, a.CreatedAt, now)
+		if bucketAcc[a.BucketID] == nil {
+			bucketAcc[a.BucketID] = &masteryAcc{}
+		}
+		bucketAcc[a.BucketID].add(a.IsCorrect, a.CreatedAt, now)
+
+		if a.FileID != nil {
+			if topicAcc[a.BucketID] == nil {
+				topicAcc[a.BucketID] = make(map[uint]*masteryAcc)
+			}
+			if topicAcc[a.BucketID][*a.FileID] == nil {
+				topicAcc[a.BucketID][*a.FileID] = &masteryAcc{}
+			}
+			topicAcc[a.BucketID][*a.FileID].add(a.IsCorrect, a.CreatedAt, now)
+			if a.Filename != nil {
+				topicNames[*a.FileID] = *a.Filename
+			}
+		}
+
+		if a.ChunkID != nil && a.FileID != nil {
+			if chunkAcc[*a.ChunkID] == nil {
+				chunkAcc[*a.ChunkID] = &masteryAcc{}
+				wc := WeakConcept{ChunkID: *a.ChunkID, FileID: *a.FileID, BucketID: a.BucketID}
+				if a.ChunkIndex != nil {
+					wc.ChunkIndex = *a.ChunkIndex
+				}
+				if a.Filename != nil {
+					wc.Filename = *a.Filename
+				}
+				if a.HeadingPath != nil {
+					wc.HeadingPath = *a.HeadingPath
+				}
+				wc.Pages = formatPages(a.PageStart, a.PageEnd)
+				chunkInfo[*a.ChunkID] = wc
+			}
+			chunkAcc[*a.ChunkID].add(a.IsCorrect, a.CreatedAt, now)
+		}
+	}
+
+	p := Progress{
+		Overall: overall.mastery(),
+		Trend:   dailyTrend(attempts),
+		Streak:  computeStreak(attempts, now),
+	}
+
+	// Buckets appear in order of first attempt.
+	seen := make(map[uint]bool)
+	byBucket := make(map[uint][]progressAttempt)
+	var order []progressAttempt
+	for _, at := range attempts {
+		byBucket[at.BucketID] = append(byBucket[at.BucketID], at)
+		if !seen[at.BucketID] {
+			seen[at.BucketID] = true
+			order = append(order, at)
+		}
+	}
+	for _, at := range order {
+		bp := BucketProgress{
+			BucketID:   at.BucketID,
+			BucketName: at.BucketName,
+			Trend:      dailyTrend(byBucket[at.BucketID]),
+			Topics:     []TopicProgress{},
+		}
+		if acc := bucketAcc[at.BucketID]; acc != nil {
+			bp.Mastery = acc.mastery()
+		} else {
+			bp.Mastery = (&masteryAcc{}).mastery()
+		}
+		for fileID, acc := range topicAcc[at.BucketID] {
+			bp.Topics = append(bp.Topics, TopicProgress{
+				FileID:   fileID,
+				Filename: topicNames[fileID],
+				Mastery:  acc.mastery(),
+			})
+		}
+		sort.Slice(bp.Topics, func(i, j int) bool {
+			return bp.Topics[i].Mastery.Estimate < bp.Topics[j].Mastery.Estimate
+		})
+		p.Buckets = append(p.Buckets, bp)
+	}
+
+	// Weakest concepts: only chunks the learner has actually missed.
+	for chunkID, acc := range chunkAcc {
+		if acc.correct == acc.answered {
+			continue
+		}
+		wc := chunkInfo[chunkID]
+		wc.Mastery = acc.mastery()
+		p.WeakConcepts = append(p.WeakConcepts, wc)
+	}
+	sort.Slice(p.WeakConcepts, func(i, j int) bool {
+		a, b := p.WeakConcepts[i].Mastery, p.WeakConcepts[j].Mastery
+		if a.Estimate != b.Estimate {
+			return a.Estimate < b.Estimate
+		}
+		return a.Answered > b.Answered
+	})
+	if len(p.WeakConcepts) > weakConceptLimit {
+		p.WeakConcepts = p.WeakConcepts[:weakConceptLimit]
+	}
+	return p
+}
+
+// dailyTrend averages attempt scores per UTC day. attempts must be sorted
+// by CreatedAt.
+func dailyTrend(attempts []progressAttempt) []TrendPoint {
+	out := []TrendPoint{}
+	var sum float64
+	for _, at := range attempts {
+		day := at.CreatedAt.UTC().Format("2006-01-02")
+		if len(out) == 0 || out[len(out)-1].Date != day {
+			if len(out) > 0 {
+				out[len(out)-1].AvgScore = sum / float64(out[len(out)-1].Attempts)
+			}
+			out = append(out, TrendPoint{Date: day})
+			sum = 0
+		}
+		out[len(out)-1].Attempts++
+		sum += at.Score
+	}
+	if len(out) > 0 {
+		out[len(out)-1].AvgScore = sum / float64(out[len(out)-1].Attempts)
+	}
+	return out
+}
+
+// computeStreak walks the distinct attempt days. attempts must be sorted by
+// CreatedAt.
+func computeStreak(attempts []progressAttempt, now time.Time) Streak {
+	var s Streak
+	var prev time.Time
+	run := 0
+	for _, at := range attempts {
+		d := at.CreatedAt.UTC().Truncate(24 * time.Hour)
+		switch {
+		case run == 0:
+			run = 1
+		case d.Equal(prev):
+			continue
+		case d.Sub(prev) == 24*time.Hour:
+			run++
+		default:
+			run = 1
+		}
+		prev = d
+		if run > s.Longest {
+			s.Longest = run
+		}
+	}
+	if run == 0 {
+		return s
+	}
+	s.LastActiveDate = prev.Format("2006-01-02")
+	today := now.UTC().Truncate(24 * time.Hour)
+	if today.Sub(prev) <= 24*time.Hour {
+		s.Current = run
+	}
+	return s
+}
+
+// snippet returns at most n runes of s, with an ellipsis when truncated.
+func snippet(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:n]) + "…"
+}
@@ -0,0 +1,73 @@
+// internal/quiz/related.go
+package quiz
+
+import (
+	"log"
+	"strings"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+)
+
+const (
+	// maxRelatedNotes is how many linked notes are added to a chunk's context.
+	maxRelatedNotes = 3
+	// relatedNoteChars caps the text taken from each linked note.
+	relatedNoteChars = 1500
+)
+
+// relatedNote is the passage of a linked note closest to a source chunk.
+type relatedNote struct {
+	Filename string
+	Content  string
+}
+
+// relatedNotes returns, for each note linked to or from the chunk's file
+// (see file.NoteLink), the chunk of that note closest to this one. Notes
+// the chunk's file links to come before notes linking to it.
+func relatedNotes(ch sourceChunk) ([]relatedNote, error) {
+	var out []relatedNote
+	err := db.DB.Raw(`
+		SELECT filename, content FROM (
+			SELECT DISTINCT ON (f.id)
+				f.id AS file_id,
+				f.filename AS filename,
+				fc.content AS content,
+				(nl.from_file_id = @file) AS outgoing
+			FROM note_links nl
+			JOIN files f ON f.id = CASE WHEN nl.from_file_id = @file THEN nl.to_file_id ELSE nl.from_file_id END
+			JOIN file_chunks fc ON fc.file_id = f.id
+			WHERE (nl.from_file_id = @file OR nl.to_file_id = @file)
+				AND f.deleted_at IS NULL AND fc.deleted_at IS NULL
+			ORDER BY f.id, fc.embedding <-> (SELECT embedding FROM file_chunks WHERE id = @chunk), fc.chunk_index
+		) related
+		ORDER BY outgoing DESC, file_id
+		LIMIT @limit
+	`, map[string]interface{}{"file": ch.FileID, "chunk": ch.ID, "limit": maxRelatedNotes}).Scan(&out).Error
+	return out, err
+}
+
+// withRelatedNotes returns the chunk's text followed by excerpts of the
+// notes it is linked with, so questions can draw on connected ideas.
+// Lookup failures are logged and the chunk is used on its own.
+func withRelatedNotes(ch sourceChunk) string {
+	notes, err := relatedNotes(ch)
+	if err != nil {
+		log.Printf("[quiz.withRelatedNotes] could not load notes linked to chunk %d: %v\n", ch.ID, err)
+		return ch.Content
+	}
+	if len(notes) == 0 {
+		return ch.Content
+	}
+
+	var sb strings.Builder
+	sb.WriteString(ch.Content)
+	sb.WriteString("\n\nRelated notes:")
+	for _, n := range notes {
+		content := n.Content
+		if r := []rune(content); len(r) > relatedNoteChars {
+			content = string(r[:relatedNoteChars]) + "…"
+		}
+		sb.WriteString("\n\n## " + n.Filename + "\n" + content)
+	}
+	return sb.String()
+}
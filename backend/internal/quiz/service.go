@@ -2,21 +2,43 @@
 package quiz
 
 import (
+	"errors"
+	"fmt"
 	"log"
-	"time"
 
 	"gorm.io/gorm"
 
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/ai"
 	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/events"
 )
 
+// defaultStandardQuestions is the length of a standard quiz that doesn't
+// ask for a questionCount.
+const defaultStandardQuestions = 10
+
+// standardQuestionsPerChunk is roughly how many questions a standard quiz
+// asks about each chunk it samples.
+const standardQuestionsPerChunk = 2
+
+// standardChoices is the number of answer choices per generated question.
+const standardChoices = 4
+
+// ErrNoSourceChunks means the bucket has no processed text to quiz on.
+var ErrNoSourceChunks = errors.New("no processed files in this bucket to generate questions from")
+
 // GenerateQuiz is the “service” version of what used to live inline in cmd/worker/main.go.
 // It:
 //   1) marks quiz.status = "generating"
-//   2) (in this dummy example) sleeps, then inserts one question + two answers
+//   2) fills the quiz with questions according to quiz.Mode
 //   3) marks quiz.status = "ready" (or "failed")
-// If you plan to replace this stub with a real AI‐driven generator, swap out the “sleep + dummy insert” accordingly.
 func GenerateQuiz(quizID uint) error {
+	var qrec Quiz
+	if err := db.DB.First(&qrec, quizID).Error; err != nil {
+		log.Printf("[quiz.GenerateQuiz] could not find quiz_id=%d: %v\n", quizID, err)
+		return err
+	}
+
 	// 1) Mark quiz.status = "generating"
 	if err := db.DB.Model(&Quiz{}).
 		Where("id = ?", quizID).
@@ -24,51 +46,149 @@ func GenerateQuiz(quizID uint) error {
 		log.Printf("[quiz.GenerateQuiz] failed to set generating status: %v\n", err)
 		// continue anyway so we don’t get stuck
 	}
+	publishQuiz(quizID)
 
-	// 2) Simulate “quiz generation” work
-	time.Sleep(1 * time.Second)
-
-	// 3) Insert a dummy question + two dummy answers inside a transaction
-	err := db.DB.Transaction(func(tx *gorm.DB) error {
-		// a) Create one question
-		q := Question{
-			QuizID:      quizID,
-			Text:        "What is 2 + 2?",
-			Explanation: "Basic arithmetic: 2 + 2 = 4.",
-		}
-		if err := tx.Create(&q).Error; err != nil {
-			return err
-		}
-
-		// b) Create two answers
-		answers := []Answer{
-			{QuestionID: q.ID, Text: "3", IsCorrect: false, Explanation: "No, 2 + 2 is not 3."},
-			{QuestionID: q.ID, Text: "4", IsCorrect: true, Explanation: "Yes, 2 + 2 = 4."},
-		}
-		for _, a := range answers {
-			if err := tx.Create(&a).Error; err != nil {
-				return err
-			}
-		}
-		return nil
-	})
+	// 2) Fill the quiz according to its mode
+	var err error
+	switch qrec.Mode {
+	case ModeWeakSpots:
+		err = generateWeakSpots(qrec)
+	case ModeRetakeWrong:
+		err = generateRetakeWrong(qrec)
+	case ModeBank:
+		err = generateBank(qrec)
+	case ModeAdaptive:
+		err = prepareAdaptive(qrec)
+	case ModeFlashcards:
+		err = generateFlashcards(qrec)
+	default:
+		err = generateStandard(qrec)
+	}
 	if err != nil {
-		log.Printf("[quiz.GenerateQuiz] failed to insert dummy question/answers: %v\n", err)
+		log.Printf("[quiz.GenerateQuiz] failed to generate quiz_id=%d (mode=%s): %v\n", quizID, qrec.Mode, err)
 		// mark quiz as "failed"
+		errMsg := err.Error()
 		_ = db.DB.Model(&Quiz{}).
 			Where("id = ?", quizID).
-			Update("status", "failed").Error
+			Updates(map[string]interface{}{
+				"status":    "failed",
+				"error_msg": &errMsg,
+			}).Error
+		publishQuiz(quizID)
 		return err
 	}
 
-	// 4) Finally, mark quiz.status = "ready"
+	// 3) Finally, mark quiz.status = "ready"
 	if err := db.DB.Model(&Quiz{}).
 		Where("id = ?", quizID).
 		Update("status", "ready").Error; err != nil {
 		log.Printf("[quiz.GenerateQuiz] failed to set ready status: %v\n", err)
 		return err
 	}
+	publishQuiz(quizID)
 
 	log.Printf("[quiz.GenerateQuiz] successfully generated quiz_id=%d\n", quizID)
 	return nil
-}
\ No newline at end of file
+}
+
+// quizEvent is the status of a quiz as sent to event stream clients.
+type quizEvent struct {
+	ID     uint    `json:"id"`
+	Mode   string  `json:"mode"`
+	Status string  `json:"status"`
+	Error  *string `json:"error,omitempty"`
+}
+
+// publishQuiz announces a quiz's current status to its bucket's event
+// stream.
+func publishQuiz(quizID uint) {
+	if !events.Enabled() {
+		return
+	}
+	var q Quiz
+	if err := db.DB.First(&q, quizID).Error; err != nil {
+		return
+	}
+	events.Publish(q.BucketID, "quiz", quizEvent{ID: q.ID, Mode: q.Mode, Status: q.Status, Error: q.ErrorMsg})
+}
+
+// generateStandard asks the model for questions on a sample of the
+// bucket's chunks, spread evenly across them, and records on each question
+// the chunk it was generated from.
+func generateStandard(qrec Quiz) error {
+	count := qrec.QuestionCount
+	if count <= 0 {
+		count = defaultStandardQuestions
+	}
+
+	chunks, err := standardChunks(qrec.BucketID, (count+standardQuestionsPerChunk-1)/standardQuestionsPerChunk)
+	if err != nil {
+		return err
+	}
+	if len(chunks) == 0 {
+		return ErrNoSourceChunks
+	}
+
+	type generated struct {
+		chunkID uint
+		q       generatedQuestion
+	}
+	var out []generated
+	seen := make(map[string]bool)
+	for i, ch := range chunks {
+		// Spread the requested count across chunks, earlier chunks first.
+		n := count / len(chunks)
+		if i < count%len(chunks) {
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		raw, err := ai.GenerateQuestions(withRelatedNotes(ch), n, standardChoices, "", nil)
+		if err != nil {
+			log.Printf("[quiz.generateStandard] GenerateQuestions error (chunk %d): %v\n", ch.ID, err)
+			continue
+		}
+		parsed, err := parseGeneratedQuestions(raw)
+		if err != nil {
+			log.Printf("[quiz.generateStandard] %v (chunk %d)\n", err, ch.ID)
+			continue
+		}
+		for _, g := range parsed {
+			key := normalizeQuestionText(g.Question)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, generated{chunkID: ch.ID, q: g})
+		}
+	}
+	if len(out) == 0 {
+		return fmt.Errorf("model produced no usable questions")
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, g := range out {
+			chunkID := g.chunkID
+			if err := insertGeneratedQuestion(tx, qrec.ID, &chunkID, g.q); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// standardChunks picks up to limit random chunks of the bucket's live files.
+func standardChunks(bucketID uint, limit int) ([]sourceChunk, error) {
+	var rows []sourceChunk
+	err := db.DB.Raw(`
+		SELECT fc.id, fc.file_id, fc.content
+		FROM file_chunks fc
+		JOIN files f ON f.id = fc.file_id
+		WHERE f.bucket_id = ? AND f.status = 'completed'
+			AND fc.deleted_at IS NULL AND f.deleted_at IS NULL
+		ORDER BY random()
+		LIMIT ?
+	`, bucketID, limit).Scan(&rows).Error
+	return rows, err
+}
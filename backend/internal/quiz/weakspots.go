@@ -0,0 +1,257 @@
+// internal/quiz/weakspots.go
+package quiz
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/pgvector/pgvector-go"
+	"gorm.io/gorm"
+
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/ai"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/bucket"
+	"github.com/davidhfrankelcodes/quizgenie-backend/internal/db"
+)
+
+// defaultWeakSpotQuestions is used when a weak-spots quiz doesn't ask for a count.
+const defaultWeakSpotQuestions = 5
+
+// weakSpotChoices is the number of answer choices per generated question.
+const weakSpotChoices = 4
+
+// ErrNoWrongAnswers is returned when a review quiz is requested for a bucket
+// in which the user hasn't missed anything yet.
+var ErrNoWrongAnswers = errors.New("no incorrect answers to review in this bucket")
+
+// WrongQuestions returns the questions in bucketID whose most recent answer
+// by userID was incorrect. Copies made by earlier review quizzes are folded
+// into their original question, so answering a copy correctly clears it.
+func WrongQuestions(bucketID, userID uint) ([]Question, error) {
+	var qs []Question
+	err := db.DB.Raw(`
+		SELECT q.*
+		FROM questions q
+		JOIN (
+			SELECT DISTINCT ON (COALESCE(q2.origin_question_id, q2.id))
+				COALESCE(q2.origin_question_id, q2.id) AS root_id,
+				aa.is_correct
+			FROM attempt_answers aa
+			JOIN attempts at ON at.id = aa.attempt_id
+			JOIN questions q2 ON q2.id = aa.question_id
+			JOIN quizzes qz ON qz.id = q2.quiz_id
+			WHERE qz.bucket_id = ? AND at.user_id = ?
+				AND aa.deleted_at IS NULL AND at.deleted_at IS NULL
+			ORDER BY COALESCE(q2.origin_question_id, q2.id), aa.created_at DESC
+		) latest ON latest.root_id = q.id
+		WHERE latest.is_correct = false AND q.deleted_at IS NULL
+		ORDER BY q.id ASC
+	`, bucketID, userID).Scan(&qs).Error
+	return qs, err
+}
+
+// generateRetakeWrong fills the quiz with copies of the questions the bucket
+// owner got wrong.
+func generateRetakeWrong(qrec Quiz) error {
+	var b bucket.Bucket
+	if err := db.DB.First(&b, qrec.BucketID).Error; err != nil {
+		return err
+	}
+	wrong, err := WrongQuestions(qrec.BucketID, b.UserID)
+	if err != nil {
+		return err
+	}
+	if len(wrong) == 0 {
+		return ErrNoWrongAnswers
+	}
+	if qrec.QuestionCount > 0 && len(wrong) > qrec.QuestionCount {
+		wrong = wrong[:qrec.QuestionCount]
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, q := range wrong {
+			if err := copyQuestion(tx, qrec.ID, q); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// generateWeakSpots finds the source chunks behind the questions the bucket
+// owner got wrong and asks the model for fresh questions on exactly those
+// chunks, telling it which questions not to repeat.
+func generateWeakSpots(qrec Quiz) error {
+	var b bucket.Bucket
+	if err := db.DB.First(&b, qrec.BucketID).Error; err != nil {
+		return err
+	}
+	wrong, err := WrongQuestions(qrec.BucketID, b.UserID)
+	if err != nil {
+		return err
+	}
+	if len(wrong) == 0 {
+		return ErrNoWrongAnswers
+	}
+
+	count := qrec.QuestionCount
+	if count <= 0 {
+		count = defaultWeakSpotQuestions
+	}
+
+	chunks, err := weakSpotChunks(qrec.BucketID, wrong, count)
+	if err != nil {
+		return err
+	}
+	if len(chunks) == 0 {
+		return fmt.Errorf("could not locate source material for the missed questions")
+	}
+
+	// Old question texts are passed to the model and also filtered out
+	// afterwards in case it repeats one anyway.
+	var avoid []string
+	seen := make(map[string]bool)
+	for _, q := range wrong {
+		avoid = append(avoid, q.Text)
+		seen[normalizeQuestionText(q.Text)] = true
+	}
+
+	type generated struct {
+		chunkID uint
+		q       generatedQuestion
+	}
+	var out []generated
+	for i, ch := range chunks {
+		// Spread the requested count across chunks, earlier chunks first.
+		n := count / len(chunks)
+		if i < count%len(chunks) {
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		raw, err := ai.GenerateQuestions(withRelatedNotes(ch), n, weakSpotChoices, "", avoid)
+		if err != nil {
+			log.Printf("[quiz.generateWeakSpots] GenerateQuestions error (chunk %d): %v\n", ch.ID, err)
+			continue
+		}
+		parsed, err := parseGeneratedQuestions(raw)
+		if err != nil {
+			log.Printf("[quiz.generateWeakSpots] %v (chunk %d)\n", err, ch.ID)
+			continue
+		}
+		for _, g := range parsed {
+			key := normalizeQuestionText(g.Question)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			out = append(out, generated{chunkID: ch.ID, q: g})
+		}
+	}
+	if len(out) == 0 {
+		return fmt.Errorf("model produced no usable questions")
+	}
+
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		for _, g := range out {
+			chunkID := g.chunkID
+			if err := insertGeneratedQuestion(tx, qrec.ID, &chunkID, g.q); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// sourceChunk is the slice of file_chunks a weak-spots quiz is generated from.
+type sourceChunk struct {
+	ID      uint
+	FileID  uint
+	Content string
+}
+
+// weakSpotChunks maps missed questions to the chunks they came from, falling
+// back to the nearest chunk in the bucket by embedding when a question has no
+// (surviving) source. At most limit distinct chunks are returned, in the order
+// the questions were missed.
+func weakSpotChunks(bucketID uint, wrong []Question, limit int) ([]sourceChunk, error) {
+	var ids []uint
+	for _, q := range wrong {
+		if q.SourceChunkID != nil {
+			ids = append(ids, *q.SourceChunkID)
+		}
+	}
+	live := make(map[uint]sourceChunk)
+	if len(ids) > 0 {
+		var rows []sourceChunk
+		if err := db.DB.Table("file_chunks").
+			Select("file_chunks.id, file_chunks.file_id, file_chunks.content").
+			Joins("JOIN files ON files.id = file_chunks.file_id").
+			Where("file_chunks.id IN ? AND files.bucket_id = ?", ids, bucketID).
+			Where("file_chunks.deleted_at IS NULL AND files.deleted_at IS NULL").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			live[r.ID] = r
+		}
+	}
+
+	var out []sourceChunk
+	picked := make(map[uint]bool)
+	for _, q := range wrong {
+		if len(out) >= limit {
+			break
+		}
+		var ch sourceChunk
+		found := false
+		if q.SourceChunkID != nil {
+			ch, found = live[*q.SourceChunkID]
+		}
+		if !found {
+			nearest, err := nearestChunk(bucketID, q.Text)
+			if err != nil {
+				log.Printf("[quiz.weakSpotChunks] nearest chunk lookup failed for question %d: %v\n", q.ID, err)
+				continue
+			}
+			if nearest == nil {
+				continue
+			}
+			ch = *nearest
+		}
+		if picked[ch.ID] {
+			continue
+		}
+		picked[ch.ID] = true
+		out = append(out, ch)
+	}
+	return out, nil
+}
+
+// nearestChunk embeds text and returns the closest embedded chunk in the
+// bucket, or nil if the bucket has none.
+func nearestChunk(bucketID uint, text string) (*sourceChunk, error) {
+	emb, err := ai.GetEmbedding(text)
+	if err != nil {
+		return nil, err
+	}
+	vec := pgvector.NewVector(emb)
+
+	var rows []sourceChunk
+	if err := db.DB.Raw(`
+		SELECT fc.id, fc.file_id, fc.content
+		FROM file_chunks fc
+		JOIN files f ON f.id = fc.file_id
+		WHERE f.bucket_id = ? AND fc.embedding IS NOT NULL
+			AND fc.deleted_at IS NULL AND f.deleted_at IS NULL
+		ORDER BY fc.embedding <-> ?
+		LIMIT 1
+	`, bucketID, vec).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return &rows[0], nil
+}
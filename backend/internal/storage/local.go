@@ -0,0 +1,98 @@
+// internal/storage/local.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Local stores blobs as files under a root directory, one per key.
+type Local struct {
+	Root string
+}
+
+// NewLocal returns a store rooted at dir (created on first write).
+func NewLocal(dir string) *Local {
+	return &Local{Root: dir}
+}
+
+func (l *Local) localPath(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+	return filepath.Join(l.Root, filepath.FromSlash(key)), nil
+}
+
+// Put writes to a temporary file next to the destination and renames it
+// into place once complete.
+func (l *Local) Put(ctx context.Context, key string, r io.Reader) error {
+	dst, err := l.localPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (l *Local) Open(ctx context.Context, key string) (Blob, error) {
+	p, err := l.localPath(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return localBlob{f, info.Size()}, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.localPath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+type localBlob struct {
+	*os.File
+	size int64
+}
+
+func (b localBlob) Size() int64 { return b.size }
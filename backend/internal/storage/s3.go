@@ -0,0 +1,99 @@
+// internal/storage/s3.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3PartSize is the multipart chunk size for uploads of unknown length.
+// minio-go buffers one part per upload in memory.
+const s3PartSize = 16 << 20
+
+// S3 stores blobs as objects in a bucket of an S3-compatible service (AWS
+// S3, MinIO, ...), with the key as object name.
+type S3 struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3FromEnv connects to S3_ENDPOINT (host[:port]) with S3_ACCESS_KEY and
+// S3_SECRET_KEY, using TLS unless S3_USE_SSL=false, and creates S3_BUCKET
+// (in S3_REGION, if set) when it doesn't exist yet.
+func NewS3FromEnv(ctx context.Context) (*S3, error) {
+	endpoint, bucket := os.Getenv("S3_ENDPOINT"), os.Getenv("S3_BUCKET")
+	if endpoint == "" || bucket == "" {
+		return nil, errors.New("S3_ENDPOINT and S3_BUCKET must be set")
+	}
+	useSSL := true
+	if v := os.Getenv("S3_USE_SSL"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid S3_USE_SSL %q", v)
+		}
+		useSSL = b
+	}
+	region := os.Getenv("S3_REGION")
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"), ""),
+		Secure: useSSL,
+		Region: region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := client.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach bucket %s: %w", bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: region}); err != nil {
+			return nil, fmt.Errorf("could not create bucket %s: %w", bucket, err)
+		}
+	}
+	return &S3{client: client, bucket: bucket}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, -1, minio.PutObjectOptions{PartSize: s3PartSize})
+	return err
+}
+
+func (s *S3) Open(ctx context.Context, key string) (Blob, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy; Stat makes the request and tells a missing key apart.
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+		}
+		return nil, err
+	}
+	return s3Blob{obj, info.Size}, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+type s3Blob struct {
+	*minio.Object
+	size int64
+}
+
+func (b s3Blob) Size() int64 { return b.size }
@@ -0,0 +1,119 @@
+// internal/storage/storage.go
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned by Open for a key with nothing stored under it.
+var ErrNotFound = errors.New("blob not found")
+
+// Storage keeps uploaded files ("blobs") under slash-separated keys such as
+// "user_1/bucket_2/notes.pdf", so the API and workers don't need a shared
+// volume.
+type Storage interface {
+	// Put streams r to key, replacing whatever was stored there. Readers
+	// never see a partially written blob.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open returns the blob stored under key, or ErrNotFound.
+	Open(ctx context.Context, key string) (Blob, error)
+	// Delete removes the blob under key. A missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Blob is an open stored file. It supports random access, which PDF and
+// zip readers need.
+type Blob interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+	Size() int64
+}
+
+// Store is the configured backend, set by InitStorage.
+var Store Storage
+
+// InitStorage sets Store from STORAGE_BACKEND: "local" (the default) keeps
+// blobs under FILE_STORAGE_PATH, "s3" in the S3_BUCKET of an S3-compatible
+// service such as MinIO (see NewS3FromEnv).
+func InitStorage() {
+	var err error
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "local":
+		Store = NewLocal(os.Getenv("FILE_STORAGE_PATH"))
+		log.Println("✅ Storing files on the local filesystem")
+	case "s3":
+		Store, err = NewS3FromEnv(context.Background())
+		if err != nil {
+			log.Fatalf("failed to set up S3 storage: %v", err)
+		}
+		log.Println("✅ Storing files in S3 bucket", os.Getenv("S3_BUCKET"))
+	default:
+		log.Fatalf("unknown STORAGE_BACKEND %q (want local or s3)", backend)
+	}
+}
+
+// ValidKey reports whether key is a clean relative slash path that stays
+// inside the store.
+func ValidKey(key string) bool {
+	return key != "" && key != "." && key == path.Clean(key) && !path.IsAbs(key) &&
+		key != ".." && !strings.HasPrefix(key, "../") && !strings.Contains(key, `\`)
+}
+
+// localPather is implemented by backends whose blobs already are local files.
+type localPather interface {
+	localPath(key string) (string, error)
+}
+
+// LocalPath returns a path on the local disk holding the blob under key,
+// for readers that need a file name. Blobs in remote stores are downloaded
+// to a temporary file (keeping the key's extension, which format detection
+// consults); cleanup removes it and must be called when done.
+func LocalPath(ctx context.Context, s Storage, key string) (p string, cleanup func(), err error) {
+	if lp, ok := s.(localPather); ok {
+		p, err := lp.localPath(key)
+		if err != nil {
+			return "", nil, err
+		}
+		if _, err := os.Stat(p); errors.Is(err, os.ErrNotExist) {
+			return "", nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+		}
+		return p, func() {}, nil
+	}
+
+	blob, err := s.Open(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+	defer blob.Close()
+	tmp, err := os.CreateTemp("", "quizgenie-*"+path.Ext(key))
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.Remove(tmp.Name()) }
+	_, err = io.Copy(tmp, blob)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("could not download %s: %w", key, err)
+	}
+	return tmp.Name(), cleanup, nil
+}
+
+// ReadAll returns the whole blob under key.
+func ReadAll(ctx context.Context, s Storage, key string) ([]byte, error) {
+	blob, err := s.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+	return io.ReadAll(blob)
+}
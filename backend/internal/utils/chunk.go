@@ -1,15 +1,197 @@
 // internal/utils/chunk.go
 package utils
 
-// ChunkText splits a large string into slices of at most maxChars characters each.
-func ChunkText(fullText string, maxChars int) []string {
-	var chunks []string
-	for start := 0; start < len(fullText); start += maxChars {
-		end := start + maxChars
-		if end > len(fullText) {
-			end = len(fullText)
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ChunkOptions sets the size of chunks in tokens (see TokenEncoding).
+type ChunkOptions struct {
+	MaxTokens     int // upper bound per chunk
+	OverlapTokens int // trailing text of a chunk repeated at the start of the next, in whole sentences
+}
+
+// DefaultChunkOptions keeps chunks around the size of the old 2000-character
+// chunks, with about two sentences of overlap.
+var DefaultChunkOptions = ChunkOptions{MaxTokens: 500, OverlapTokens: 50}
+
+var (
+	paragraphBreak = regexp.MustCompile(`\n[ \t]*\n\s*`)
+	// sentenceEnd matches the end of a sentence: terminal punctuation, any
+	// closing quotes or brackets, then whitespace.
+	sentenceEnd = regexp.MustCompile(`[.!?…]+["'”’)\]]*\s+`)
+)
+
+// textUnit is the smallest piece ChunkText moves around: a sentence, or a
+// slice of one too long to fit in a chunk on its own.
+type textUnit struct {
+	sep    string // what joins it to the previous unit: "\n\n", "\n", " " or ""
+	text   string
+	tokens int
+}
+
+// ChunkText splits text into chunks of at most opts.MaxTokens tokens. It
+// breaks between paragraphs where it can, otherwise between lines or
+// sentences, and only cuts inside a sentence (on a rune boundary) when the
+// sentence alone is too long. Each chunk after the first starts with up to
+// opts.OverlapTokens of whole sentences from the end of the previous one.
+func ChunkText(fullText string, opts ChunkOptions) []string {
+	units := splitUnits(fullText, opts.MaxTokens)
+
+	var (
+		out     []string
+		cur     []textUnit
+		tokens  int
+		carried int // leading units of cur repeated from the previous chunk
+	)
+	cost := func(u textUnit, first bool) int {
+		if first || u.sep == "" {
+			return u.tokens
+		}
+		return u.tokens + 1
+	}
+	for i := 0; i < len(units); {
+		u := units[i]
+		if len(cur) == carried || tokens+cost(u, len(cur) == 0) <= opts.MaxTokens {
+			// Never emit a chunk made only of overlap: drop overlap that
+			// leaves no room for new text.
+			for len(cur) > 0 && len(cur) == carried && tokens+cost(u, false) > opts.MaxTokens {
+				cur, carried = cur[1:], carried-1
+				tokens = unitTokens(cur, cost)
+			}
+			tokens += cost(u, len(cur) == 0)
+			cur = append(cur, u)
+			i++
+			continue
+		}
+
+		// Full: end the chunk at the last paragraph break if that keeps it at
+		// least half full, otherwise here.
+		cut := len(cur)
+		for j := len(cur) - 1; j > carried; j-- {
+			if cur[j].sep == "\n\n" {
+				if unitTokens(cur[:j], cost) >= opts.MaxTokens/2 {
+					cut = j
+				}
+				break
+			}
+		}
+		emitted, rest := cur[:cut], cur[cut:]
+		out = append(out, joinUnits(emitted))
+
+		overlap := overlapUnits(emitted[carried:], opts.OverlapTokens)
+		cur = append(append([]textUnit(nil), overlap...), rest...)
+		carried = len(overlap)
+		tokens = unitTokens(cur, cost)
+	}
+	if len(cur) > carried {
+		out = append(out, joinUnits(cur))
+	}
+	return out
+}
+
+// splitUnits breaks text into paragraphs, lines and sentences, splitting any
+// sentence longer than maxTokens.
+func splitUnits(text string, maxTokens int) []textUnit {
+	var units []textUnit
+	for _, para := range paragraphBreak.Split(strings.TrimSpace(text), -1) {
+		sep := "\n\n"
+		for _, line := range strings.Split(para, "\n") {
+			line = strings.TrimRight(line, " \t\r")
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			for _, sentence := range splitSentences(line) {
+				n := CountTokens(sentence)
+				if n <= maxTokens {
+					units = append(units, textUnit{sep: sep, text: sentence, tokens: n})
+				} else {
+					for k, piece := range splitByTokens(sentence, maxTokens) {
+						s := sep
+						if k > 0 {
+							s = ""
+						}
+						units = append(units, textUnit{sep: s, text: piece, tokens: CountTokens(piece)})
+					}
+				}
+				sep = " "
+			}
+			sep = "\n"
+		}
+	}
+	if len(units) > 0 {
+		units[0].sep = ""
+	}
+	return units
+}
+
+// splitSentences splits a line after each sentence-ending punctuation mark.
+func splitSentences(line string) []string {
+	var out []string
+	start := 0
+	for _, m := range sentenceEnd.FindAllStringIndex(line, -1) {
+		if s := strings.TrimSpace(line[start:m[1]]); s != "" {
+			out = append(out, s)
+		}
+		start = m[1]
+	}
+	if s := strings.TrimSpace(line[start:]); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
+
+// splitByTokens cuts s into pieces of at most maxTokens tokens. A token can
+// hold part of a multi-byte rune, so each cut is moved to the nearest point
+// where the piece decodes to valid UTF-8.
+func splitByTokens(s string, maxTokens int) []string {
+	enc := tokenizer()
+	toks := enc.EncodeOrdinary(s)
+	var out []string
+	for start := 0; start < len(toks); {
+		end := min(start+maxTokens, len(toks))
+		piece := enc.Decode(toks[start:end])
+		for e := end - 1; !utf8.ValidString(piece) && e > start; e-- {
+			end, piece = e, enc.Decode(toks[start:e])
+		}
+		for e := end + 1; !utf8.ValidString(piece) && e <= len(toks); e++ {
+			end, piece = e, enc.Decode(toks[start:e])
+		}
+		out = append(out, piece)
+		start = end
+	}
+	return out
+}
+
+// overlapUnits returns the longest run of trailing units totalling at most
+// budget tokens, leaving at least one unit behind.
+func overlapUnits(units []textUnit, budget int) []textUnit {
+	total := 0
+	i := len(units)
+	for i > 1 && total+units[i-1].tokens+1 <= budget {
+		total += units[i-1].tokens + 1
+		i--
+	}
+	return units[i:]
+}
+
+func unitTokens(units []textUnit, cost func(textUnit, bool) int) int {
+	n := 0
+	for i, u := range units {
+		n += cost(u, i == 0)
+	}
+	return n
+}
+
+func joinUnits(units []textUnit) string {
+	var b strings.Builder
+	for i, u := range units {
+		if i > 0 {
+			b.WriteString(u.sep)
 		}
-		chunks = append(chunks, fullText[start:end])
+		b.WriteString(u.text)
 	}
-	return chunks
+	return b.String()
 }
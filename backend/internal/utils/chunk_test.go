@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// numberedSentences returns n distinct sentences, so chunks can be matched
+// back to their source sentence by sentence.
+func numberedSentences(n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = fmt.Sprintf("Sentence number %d talks about topic %d in a few words.", i, i%7)
+	}
+	return out
+}
+
+// chunkSentences splits a chunk back into its sentences.
+func chunkSentences(chunk string) []string {
+	var out []string
+	for _, line := range strings.Split(chunk, "\n") {
+		out = append(out, splitSentences(line)...)
+	}
+	return out
+}
+
+func TestChunkTextEmpty(t *testing.T) {
+	for _, in := range []string{"", " ", "\n\n\t \n", "\r\n  \r\n"} {
+		if got := ChunkText(in, DefaultChunkOptions); len(got) != 0 {
+			t.Errorf("ChunkText(%q) = %q, want no chunks", in, got)
+		}
+	}
+}
+
+func TestChunkTextShortText(t *testing.T) {
+	got := ChunkText("  One sentence. Another one.\n\nA new paragraph.  ", DefaultChunkOptions)
+	want := "One sentence. Another one.\n\nA new paragraph."
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("ChunkText = %q, want [%q]", got, want)
+	}
+}
+
+func TestChunkTextMaxTokens(t *testing.T) {
+	sentences := numberedSentences(120)
+	var paragraphs []string
+	for i := 0; i < len(sentences); i += 5 {
+		paragraphs = append(paragraphs, strings.Join(sentences[i:i+5], " "))
+	}
+	inputs := map[string]string{
+		"prose":          strings.Join(paragraphs, "\n\n"),
+		"one paragraph":  strings.Join(sentences, " "),
+		"lines":          strings.Join(sentences, "\n"),
+		"long sentence":  strings.Repeat("word ", 700),
+		"no spaces":      strings.Repeat("abcdefghij", 300),
+		"multi-byte":     strings.Repeat("naïve café déjà vu, ", 150),
+		"cjk":            strings.Repeat("这是一个很长的句子没有任何标点符号", 60),
+		"mixed lengths":  strings.Join(paragraphs[:3], "\n\n") + "\n\n" + strings.Repeat("€", 900) + "\n\n" + paragraphs[3],
+		"emoji sentence": strings.Repeat("🙂👍🏽", 300) + ".",
+	}
+	for _, opts := range []ChunkOptions{
+		{MaxTokens: 50, OverlapTokens: 0},
+		{MaxTokens: 50, OverlapTokens: 20},
+		{MaxTokens: 120, OverlapTokens: 40},
+		DefaultChunkOptions,
+	} {
+		for name, in := range inputs {
+			t.Run(fmt.Sprintf("%s/%d+%d", name, opts.MaxTokens, opts.OverlapTokens), func(t *testing.T) {
+				chunks := ChunkText(in, opts)
+				if len(chunks) == 0 {
+					t.Fatal("no chunks")
+				}
+				for i, c := range chunks {
+					if n := CountTokens(c); n > opts.MaxTokens {
+						t.Errorf("chunk %d has %d tokens, max %d", i, n, opts.MaxTokens)
+					}
+					if !utf8.ValidString(c) {
+						t.Errorf("chunk %d is not valid UTF-8", i)
+					}
+					// A piece of an over-long sentence may keep the space its
+					// first token starts with; trimming it could cost a token.
+					if strings.TrimSpace(c) == "" {
+						t.Errorf("chunk %d is blank", i)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestChunkTextOverlap(t *testing.T) {
+	sentences := numberedSentences(60)
+	known := make(map[string]bool)
+	for _, s := range sentences {
+		known[s] = true
+	}
+	in := strings.Join(sentences, " ")
+
+	for _, opts := range []ChunkOptions{
+		{MaxTokens: 60, OverlapTokens: 0},
+		{MaxTokens: 60, OverlapTokens: 15},
+		{MaxTokens: 60, OverlapTokens: 30},
+		{MaxTokens: 60, OverlapTokens: 60}, // overlap as large as a chunk
+		{MaxTokens: 100, OverlapTokens: 50},
+	} {
+		t.Run(fmt.Sprintf("%d+%d", opts.MaxTokens, opts.OverlapTokens), func(t *testing.T) {
+			chunks := ChunkText(in, opts)
+			if len(chunks) < 2 {
+				t.Fatalf("got %d chunks, want several", len(chunks))
+			}
+
+			var prev []string
+			next := 0       // index of the first sentence not yet emitted
+			overlapped := 0 // chunks starting with repeated sentences
+			for i, c := range chunks {
+				cur := chunkSentences(c)
+				for _, s := range cur {
+					if !known[s] {
+						t.Fatalf("chunk %d contains %q, which is not a whole input sentence", i, s)
+					}
+				}
+
+				// The chunk starts with a (possibly empty) tail of the
+				// previous chunk, then continues with new sentences in order.
+				k := 0
+				for k < len(cur) && k < len(prev) && sentencesIndex(sentences, cur[k]) < next {
+					k++
+				}
+				if k > 0 {
+					overlapped++
+					overlap := strings.Join(cur[:k], " ")
+					if !strings.HasSuffix(strings.Join(prev, " "), overlap) {
+						t.Errorf("chunk %d starts with %q, which is not the end of chunk %d", i, overlap, i-1)
+					}
+					if n := CountTokens(overlap); n > opts.OverlapTokens {
+						t.Errorf("chunk %d repeats %d tokens, overlap is %d", i, n, opts.OverlapTokens)
+					}
+				}
+				if k == len(cur) {
+					t.Fatalf("chunk %d is made only of overlap: %q", i, c)
+				}
+				for _, s := range cur[k:] {
+					if got := sentencesIndex(sentences, s); got != next {
+						t.Fatalf("chunk %d: got sentence %d, want %d", i, got, next)
+					}
+					next++
+				}
+				if opts.OverlapTokens == 0 && k > 0 {
+					t.Errorf("chunk %d repeats text with no overlap configured", i)
+				}
+				prev = cur
+			}
+			if next != len(sentences) {
+				t.Errorf("chunks cover %d of %d sentences", next, len(sentences))
+			}
+			// Every sentence fits in 30 tokens, so such a budget repeats one
+			// whenever it leaves room for new text.
+			if opts.OverlapTokens >= 30 && opts.OverlapTokens <= opts.MaxTokens/2 && overlapped != len(chunks)-1 {
+				t.Errorf("%d of %d later chunks start with overlap", overlapped, len(chunks)-1)
+			}
+		})
+	}
+}
+
+func sentencesIndex(sentences []string, s string) int {
+	for i, x := range sentences {
+		if x == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestChunkTextParagraphBreaks(t *testing.T) {
+	sentences := numberedSentences(12)
+	opts := ChunkOptions{MaxTokens: 80}
+
+	// A first paragraph filling more than half a chunk ends it at the break.
+	long := strings.Join(sentences[:5], " ") + "\n\n" + strings.Join(sentences[5:], " ")
+	if CountTokens(strings.Join(sentences[:5], " ")) < opts.MaxTokens/2 {
+		t.Fatal("test paragraph is too short")
+	}
+	if got := ChunkText(long, opts); got[0] != strings.Join(sentences[:5], " ") {
+		t.Errorf("first chunk = %q, want the whole first paragraph", got[0])
+	}
+
+	// A short first paragraph is not worth a chunk of its own.
+	short := sentences[0] + "\n\n" + strings.Join(sentences[1:], " ")
+	if got := ChunkText(short, opts); !strings.HasPrefix(got[0], sentences[0]+"\n\n"+sentences[1]) {
+		t.Errorf("first chunk = %q, want it to run past the short first paragraph", got[0])
+	}
+}
+
+func TestSplitByTokens(t *testing.T) {
+	inputs := []string{
+		strings.Repeat("ünïcödé ", 100),
+		strings.Repeat("日本語のテキスト", 80),
+		strings.Repeat("🙂👍🏽🇩🇪", 100),
+		strings.Repeat("plain ascii ", 100),
+	}
+	for _, in := range inputs {
+		for _, max := range []int{1, 3, 10, 64} {
+			pieces := splitByTokens(in, max)
+			if got := strings.Join(pieces, ""); got != in {
+				t.Errorf("max %d: pieces of %.20q… do not join back to the input", max, in)
+			}
+			for i, p := range pieces {
+				if !utf8.ValidString(p) {
+					t.Errorf("max %d: piece %d of %.20q… is not valid UTF-8: %q", max, i, in, p)
+				}
+				if p == "" {
+					t.Errorf("max %d: piece %d is empty", max, i)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// internal/utils/docx.go
+package utils
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ExtractTextFromDOCX reads word/document.xml from a .docx file and returns
+// its text as Markdown, keeping headings ("#" per level), list items ("- ",
+// indented by nesting level) and tables ("| cell | cell |" rows).
+func ExtractTextFromDOCX(path string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", fmt.Errorf("could not open DOCX: %w", err)
+	}
+	defer zr.Close()
+
+	styles, err := docxHeadingStyles(&zr.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	rc, err := openZipPart(&zr.Reader, "word/document.xml")
+	if err != nil {
+		return "", fmt.Errorf("could not open DOCX body: %w", err)
+	}
+	defer rc.Close()
+
+	blocks, err := parseDOCXBody(rc, styles)
+	if err != nil {
+		return "", fmt.Errorf("could not parse DOCX body: %w", err)
+	}
+	return strings.Join(blocks, "\n\n"), nil
+}
+
+// docxHeadingStyles maps paragraph style IDs to heading levels using
+// word/styles.xml. Style IDs are localized ("berschrift1" in German Word),
+// but style names are not ("heading 1"), so names are what we match on.
+func docxHeadingStyles(zr *zip.Reader) (map[string]int, error) {
+	out := make(map[string]int)
+	if !hasZipPart(zr, "word/styles.xml") {
+		return out, nil
+	}
+	rc, err := openZipPart(zr, "word/styles.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var doc struct {
+		Styles []struct {
+			ID   string `xml:"styleId,attr"`
+			Name struct {
+				Val string `xml:"val,attr"`
+			} `xml:"name"`
+		} `xml:"style"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("could not parse DOCX styles: %w", err)
+	}
+	for _, s := range doc.Styles {
+		name := strings.ToLower(strings.TrimSpace(s.Name.Val))
+		switch {
+		case name == "title":
+			out[s.ID] = 1
+		case strings.HasPrefix(name, "heading "):
+			if lvl, err := strconv.Atoi(strings.TrimPrefix(name, "heading ")); err == nil && lvl >= 1 && lvl <= 6 {
+				out[s.ID] = lvl
+			}
+		}
+	}
+	return out, nil
+}
+
+// docxParagraph collects one <w:p> while it is being read.
+type docxParagraph struct {
+	text         strings.Builder
+	style        string
+	listLevel    int // -1 when not a list item
+	outlineLevel int // -1 when unset
+}
+
+// docxTable collects one <w:tbl>, cell by cell.
+type docxTable struct {
+	rows [][]string
+	row  []string
+	cell []string
+}
+
+// render turns the table into Markdown-style rows.
+func (t *docxTable) render() string {
+	var lines []string
+	for _, r := range t.rows {
+		lines = append(lines, "| "+strings.Join(r, " | ")+" |")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseDOCXBody streams the document part and returns one string per
+// block (paragraph or table). Paragraphs nested in another one, as in text
+// boxes, become blocks of their own; paragraphs in table cells (however
+// deeply nested) become cell text. Of markup-compatibility alternatives
+// (e.g. a text box in both DrawingML and VML), only the first is read.
+func parseDOCXBody(r io.Reader, headingStyles map[string]int) ([]string, error) {
+	dec := xml.NewDecoder(r)
+	var (
+		blocks   []string
+		paras    []*docxParagraph // open paragraphs, innermost last
+		tables   []*docxTable
+		runDepth int
+		inText   bool
+	)
+	// para is the innermost open paragraph, or nil.
+	para := func() *docxParagraph {
+		if len(paras) == 0 {
+			return nil
+		}
+		return paras[len(paras)-1]
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == nsMarkupCompat && t.Name.Local == "Fallback" {
+				if err := dec.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if t.Name.Space != nsWordML {
+				continue
+			}
+			p := para()
+			switch t.Name.Local {
+			case "p":
+				paras = append(paras, &docxParagraph{listLevel: -1, outlineLevel: -1})
+			case "pStyle":
+				if p != nil {
+					p.style = xmlAttr(t, "val")
+				}
+			case "numPr":
+				if p != nil && p.listLevel < 0 {
+					p.listLevel = 0
+				}
+			case "ilvl":
+				if p != nil {
+					if lvl, err := strconv.Atoi(xmlAttr(t, "val")); err == nil {
+						p.listLevel = lvl
+					}
+				}
+			case "outlineLvl":
+				if p != nil {
+					if lvl, err := strconv.Atoi(xmlAttr(t, "val")); err == nil {
+						p.outlineLevel = lvl
+					}
+				}
+			case "r":
+				runDepth++
+			case "t":
+				inText = true
+			case "tab":
+				// <w:tab> inside <w:tabs> defines tab stops; only runs emit one.
+				if runDepth > 0 && p != nil {
+					p.text.WriteByte('\t')
+				}
+			case "br", "cr":
+				if runDepth > 0 && p != nil {
+					p.text.WriteByte('\n')
+				}
+			case "tbl":
+				tables = append(tables, &docxTable{})
+			case "tr":
+				if len(tables) > 0 {
+					tables[len(tables)-1].row = nil
+				}
+			case "tc":
+				if len(tables) > 0 {
+					tables[len(tables)-1].cell = nil
+				}
+			}
+
+		case xml.CharData:
+			if p := para(); inText && p != nil {
+				p.text.Write(t)
+			}
+
+		case xml.EndElement:
+			if t.Name.Space != nsWordML {
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "r":
+				if runDepth > 0 {
+					runDepth--
+				}
+			case "p":
+				p := para()
+				if p == nil {
+					continue
+				}
+				paras = paras[:len(paras)-1]
+				text := strings.TrimSpace(p.text.String())
+				if text != "" {
+					if len(tables) > 0 {
+						tbl := tables[len(tables)-1]
+						tbl.cell = append(tbl.cell, strings.ReplaceAll(text, "\n", " "))
+					} else {
+						blocks = append(blocks, formatDOCXParagraph(p, text, headingStyles))
+					}
+				}
+			case "tc":
+				if len(tables) > 0 {
+					tbl := tables[len(tables)-1]
+					tbl.row = append(tbl.row, strings.Join(tbl.cell, " "))
+				}
+			case "tr":
+				if len(tables) > 0 {
+					tbl := tables[len(tables)-1]
+					tbl.rows = append(tbl.rows, tbl.row)
+				}
+			case "tbl":
+				if len(tables) == 0 {
+					continue
+				}
+				tbl := tables[len(tables)-1]
+				tables = tables[:len(tables)-1]
+				rendered := tbl.render()
+				if rendered == "" {
+					continue
+				}
+				if len(tables) > 0 {
+					// A table nested in a cell is flattened into that cell.
+					outer := tables[len(tables)-1]
+					outer.cell = append(outer.cell, strings.ReplaceAll(rendered, "\n", " "))
+				} else {
+					blocks = append(blocks, rendered)
+				}
+			}
+		}
+	}
+	return blocks, nil
+}
+
+// formatDOCXParagraph applies heading and list markup to a paragraph.
+func formatDOCXParagraph(p *docxParagraph, text string, headingStyles map[string]int) string {
+	level := headingStyles[p.style]
+	if level == 0 && p.outlineLevel >= 0 && p.outlineLevel < 6 {
+		level = p.outlineLevel + 1
+	}
+	if level > 0 {
+		return strings.Repeat("#", level) + " " + strings.ReplaceAll(text, "\n", " ")
+	}
+	if p.listLevel >= 0 {
+		return strings.Repeat("  ", p.listLevel) + "- " + text
+	}
+	return text
+}
@@ -0,0 +1,335 @@
+// internal/utils/email.go
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// emailHeaderLine matches an RFC 5322 header field ("Name: value").
+var emailHeaderLine = regexp.MustCompile(`^[!-9;-~]+:`)
+
+// emailDecoder decodes RFC 2047 encoded words ("=?UTF-8?Q?…?=") in headers,
+// in any charset x/net knows.
+var emailDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+// isEmail recognizes a single RFC 5322 message: a header block with a From
+// field and a Subject, Date or Message-ID.
+func isEmail(head []byte) bool {
+	fields := emailHeaderFields(head)
+	return fields["from"] && (fields["subject"] || fields["date"] || fields["message-id"])
+}
+
+// isMbox recognizes an mbox mailbox by its "From " separator line followed
+// by a message header.
+func isMbox(head []byte) bool {
+	if !bytes.HasPrefix(head, []byte("From ")) {
+		return false
+	}
+	_, rest, ok := bytes.Cut(head, []byte("\n"))
+	return ok && len(emailHeaderFields(rest)) > 0
+}
+
+// emailHeaderFields returns the lower-cased field names at the top of head,
+// or nil as soon as a line isn't a header or a continuation. The last line
+// may be cut off by the sniffing limit and is ignored.
+func emailHeaderFields(head []byte) map[string]bool {
+	lines := strings.Split(string(head), "\n")
+	if len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+	fields := make(map[string]bool)
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case line == "":
+			return fields
+		case line[0] == ' ' || line[0] == '\t':
+			if i == 0 {
+				return nil
+			}
+		case emailHeaderLine.MatchString(line):
+			name, _, _ := strings.Cut(line, ":")
+			fields[strings.ToLower(name)] = true
+		default:
+			return nil
+		}
+	}
+	return fields
+}
+
+// ExtractSectionsFromEmail reads an .eml message or an .mbox mailbox. Each
+// message becomes its own group of sections headed by its subject, starting
+// with its From, Date and attachment list so every message keeps that
+// context when chunked.
+func ExtractSectionsFromEmail(path string) ([]Section, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open email: %w", err)
+	}
+	b = bytes.TrimPrefix(b, utf8BOM)
+
+	raw := [][]byte{b}
+	if isMbox(b) {
+		raw = splitMbox(b)
+	}
+	var out []Section
+	for _, m := range raw {
+		msg, err := parseEmail(m)
+		if err != nil {
+			if len(raw) == 1 {
+				return nil, err
+			}
+			// One malformed message shouldn't sink a whole mailbox.
+			continue
+		}
+		out = append(out, msg.sections()...)
+	}
+	return out, nil
+}
+
+// splitMbox splits a mailbox at its "From " separator lines, undoing the
+// ">From " quoting of body lines (mboxo/mboxrd).
+func splitMbox(b []byte) [][]byte {
+	var (
+		out [][]byte
+		cur *bytes.Buffer
+	)
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(make([]byte, 64*1024), len(b)+1)
+	prevBlank := true
+	for sc.Scan() {
+		line := sc.Bytes()
+		if prevBlank && bytes.HasPrefix(line, []byte("From ")) {
+			if cur != nil {
+				out = append(out, cur.Bytes())
+			}
+			cur = new(bytes.Buffer)
+			prevBlank = false
+			continue
+		}
+		prevBlank = len(bytes.TrimRight(line, "\r")) == 0
+		if cur == nil {
+			continue
+		}
+		if unquoted := bytes.TrimLeft(line, ">"); len(unquoted) < len(line) && bytes.HasPrefix(unquoted, []byte("From ")) {
+			line = line[1:]
+		}
+		cur.Write(line)
+		cur.WriteByte('\n')
+	}
+	if cur != nil {
+		out = append(out, cur.Bytes())
+	}
+	return out
+}
+
+// emailMessage is the readable part of one message.
+type emailMessage struct {
+	Subject, From, Date string
+	Body                string
+	Attachments         []string
+}
+
+// parseEmail parses one message, preferring its text/plain body and falling
+// back to the text of its HTML body.
+func parseEmail(b []byte) (*emailMessage, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse email: %w", err)
+	}
+	m := &emailMessage{
+		Subject: decodeHeader(msg.Header.Get("Subject")),
+		From:    formatAddress(msg.Header.Get("From")),
+		Date:    msg.Header.Get("Date"),
+	}
+	if t, err := msg.Header.Date(); err == nil {
+		m.Date = t.Format("Mon, 2 Jan 2006 15:04 MST")
+	}
+
+	var parts mimeParts
+	if err := parts.walk(msg.Header, msg.Body, 0); err != nil {
+		return nil, err
+	}
+	m.Attachments = parts.attachments
+	switch {
+	case len(parts.plain) > 0:
+		m.Body = strings.Join(parts.plain, "\n\n")
+	case len(parts.html) > 0:
+		var texts []string
+		for _, h := range parts.html {
+			doc, err := ParseHTML(strings.NewReader(h))
+			if err != nil {
+				continue
+			}
+			for _, s := range doc.Sections {
+				texts = append(texts, s.Text)
+			}
+		}
+		m.Body = strings.Join(texts, "\n\n")
+	}
+	m.Body = strings.TrimSpace(strings.ReplaceAll(m.Body, "\r\n", "\n"))
+	return m, nil
+}
+
+// sections renders the message as one metadata-led section under its
+// subject. Break keeps it from merging with the previous message.
+func (m *emailMessage) sections() []Section {
+	subject := m.Subject
+	if subject == "" {
+		subject = "(no subject)"
+	}
+	var meta []string
+	if m.From != "" {
+		meta = append(meta, "From: "+m.From)
+	}
+	if m.Date != "" {
+		meta = append(meta, "Date: "+m.Date)
+	}
+	meta = append(meta, "Subject: "+subject)
+	if len(m.Attachments) > 0 {
+		meta = append(meta, "Attachments: "+strings.Join(m.Attachments, ", "))
+	}
+	text := strings.Join(meta, "\n")
+	if m.Body != "" {
+		text += "\n\n" + m.Body
+	}
+	return []Section{{HeadingPath: []string{subject}, Text: text, Break: true}}
+}
+
+// maxMIMEDepth bounds how deeply multiparts may nest.
+const maxMIMEDepth = 10
+
+// mimeParts collects the bodies and attachment names of a MIME tree.
+type mimeParts struct {
+	plain, html []string
+	attachments []string
+}
+
+// mimeHeader is satisfied by both mail.Header and the textproto.MIMEHeader
+// of a multipart part.
+type mimeHeader interface {
+	Get(key string) string
+}
+
+// walk visits a MIME entity, recursing into multiparts. Parts marked as
+// attachments (or with a filename) are only listed by name.
+func (p *mimeParts) walk(h mimeHeader, body io.Reader, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	disposition, dparams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	name := dparams["filename"]
+	if name == "" {
+		name = params["name"]
+	}
+	if disposition == "attachment" || (name != "" && !strings.HasPrefix(mediaType, "multipart/")) {
+		if name == "" {
+			name = mediaType
+		}
+		p.attachments = append(p.attachments, decodeHeader(name))
+		return nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxMIMEDepth || params["boundary"] == "" {
+			return nil
+		}
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err != nil {
+				// io.EOF, or a truncated multipart: keep the parts read so far.
+				return nil
+			}
+			if err := p.walk(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		if mediaType == "message/rfc822" {
+			p.attachments = append(p.attachments, "forwarded message")
+		}
+		return nil
+	}
+	text, err := decodePart(h.Get("Content-Transfer-Encoding"), params["charset"], body)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	if mediaType == "text/html" {
+		p.html = append(p.html, text)
+	} else {
+		p.plain = append(p.plain, text)
+	}
+	return nil
+}
+
+// decodePart undoes a part's transfer encoding and converts it to UTF-8.
+func decodePart(encoding, charsetLabel string, body io.Reader) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	if charsetLabel != "" {
+		if r, err := charset.NewReaderLabel(charsetLabel, body); err == nil {
+			body = r
+		}
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("could not decode email part: %w", err)
+	}
+	return string(b), nil
+}
+
+// decodeHeader decodes RFC 2047 encoded words, keeping the raw value if
+// they're malformed.
+func decodeHeader(s string) string {
+	if d, err := emailDecoder.DecodeHeader(s); err == nil {
+		s = d
+	}
+	return collapseSpace(s)
+}
+
+// formatAddress renders a From field as "Name <address>", or as decoded
+// text when it doesn't parse.
+func formatAddress(s string) string {
+	if s == "" {
+		return ""
+	}
+	parser := mail.AddressParser{WordDecoder: emailDecoder}
+	addrs, err := parser.ParseList(s)
+	if err != nil {
+		return decodeHeader(s)
+	}
+	var out []string
+	for _, a := range addrs {
+		if a.Name != "" {
+			out = append(out, fmt.Sprintf("%s <%s>", a.Name, a.Address))
+		} else {
+			out = append(out, a.Address)
+		}
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,317 @@
+// internal/utils/epub.go
+package utils
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ExtractSectionsFromEPUB walks an .epub's spine in reading order and
+// returns the text of each chapter, with the chapter title (from the table
+// of contents, falling back to the chapter's own <title>) at the top of the
+// heading path and the chapter's inner headings below it.
+func ExtractSectionsFromEPUB(path string) ([]Section, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open EPUB: %w", err)
+	}
+	defer zr.Close()
+
+	pkg, err := readEPUBPackage(&zr.Reader)
+	if err != nil {
+		return nil, err
+	}
+	titles, err := epubTOCTitles(&zr.Reader, pkg)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Section
+	for _, item := range pkg.spine {
+		rc, err := openZipPart(&zr.Reader, item.path)
+		if err != nil {
+			return nil, fmt.Errorf("could not open EPUB chapter %s: %w", item.path, err)
+		}
+		doc, err := ParseHTML(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not parse EPUB chapter %s: %w", item.path, err)
+		}
+
+		chapter := titles[item.path]
+		if chapter == "" {
+			chapter = doc.Title
+		}
+		for _, s := range doc.Sections {
+			out = append(out, Section{HeadingPath: chapterPath(chapter, s.HeadingPath), Text: s.Text})
+		}
+	}
+	return out, nil
+}
+
+// chapterPath puts the chapter title in front of a section's own headings,
+// unless the chapter already opens with that title as its first heading.
+func chapterPath(chapter string, inner []string) []string {
+	if chapter == "" {
+		return inner
+	}
+	if len(inner) > 0 && strings.EqualFold(collapseSpace(inner[0]), collapseSpace(chapter)) {
+		return inner
+	}
+	return append([]string{chapter}, inner...)
+}
+
+// epubItem is a manifest entry, with its href resolved to a zip path.
+type epubItem struct {
+	id         string
+	path       string
+	mediaType  string
+	properties string
+}
+
+// epubPackage is what we need from the OPF package document.
+type epubPackage struct {
+	manifest map[string]epubItem
+	spine    []epubItem // linear (X)HTML documents in reading order
+	tocID    string     // manifest id of the EPUB 2 NCX, from <spine toc="…">
+}
+
+// readEPUBPackage locates the OPF through META-INF/container.xml and reads
+// its manifest and spine.
+func readEPUBPackage(zr *zip.Reader) (*epubPackage, error) {
+	rc, err := openZipPart(zr, "META-INF/container.xml")
+	if err != nil {
+		return nil, fmt.Errorf("could not open EPUB container: %w", err)
+	}
+	var container struct {
+		Rootfiles []struct {
+			FullPath  string `xml:"full-path,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	err = xml.NewDecoder(rc).Decode(&container)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse EPUB container: %w", err)
+	}
+	opfPath := ""
+	for _, r := range container.Rootfiles {
+		if r.MediaType == "" || r.MediaType == "application/oebps-package+xml" {
+			opfPath = r.FullPath
+			break
+		}
+	}
+	if opfPath == "" {
+		return nil, fmt.Errorf("EPUB container names no package document")
+	}
+
+	rc, err = openZipPart(zr, opfPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open EPUB package: %w", err)
+	}
+	var opf struct {
+		Items []struct {
+			ID         string `xml:"id,attr"`
+			Href       string `xml:"href,attr"`
+			MediaType  string `xml:"media-type,attr"`
+			Properties string `xml:"properties,attr"`
+		} `xml:"manifest>item"`
+		Spine struct {
+			TOC      string `xml:"toc,attr"`
+			ItemRefs []struct {
+				IDRef  string `xml:"idref,attr"`
+				Linear string `xml:"linear,attr"`
+			} `xml:"itemref"`
+		} `xml:"spine"`
+	}
+	err = xml.NewDecoder(rc).Decode(&opf)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse EPUB package: %w", err)
+	}
+
+	pkg := &epubPackage{manifest: make(map[string]epubItem), tocID: opf.Spine.TOC}
+	for _, it := range opf.Items {
+		pkg.manifest[it.ID] = epubItem{
+			id:         it.ID,
+			path:       resolveHref(opfPath, it.Href),
+			mediaType:  it.MediaType,
+			properties: it.Properties,
+		}
+	}
+	for _, ref := range opf.Spine.ItemRefs {
+		item, ok := pkg.manifest[ref.IDRef]
+		if !ok || ref.Linear == "no" {
+			continue
+		}
+		if item.mediaType != "application/xhtml+xml" && item.mediaType != "text/html" {
+			continue
+		}
+		// The EPUB 3 navigation document may be in the spine; it is a table
+		// of contents, not content.
+		if hasProperty(item.properties, "nav") {
+			continue
+		}
+		pkg.spine = append(pkg.spine, item)
+	}
+	return pkg, nil
+}
+
+// epubTOCTitles maps chapter paths to their table-of-contents titles, using
+// the EPUB 3 navigation document when there is one and the EPUB 2 NCX
+// otherwise. When several entries point into the same file the first
+// (outermost) one wins. A book without either yields an empty map.
+func epubTOCTitles(zr *zip.Reader, pkg *epubPackage) (map[string]string, error) {
+	for _, item := range pkg.manifest {
+		if hasProperty(item.properties, "nav") {
+			return epubNavTitles(zr, item.path)
+		}
+	}
+	if ncx, ok := pkg.manifest[pkg.tocID]; ok {
+		return epubNCXTitles(zr, ncx.path)
+	}
+	for _, item := range pkg.manifest {
+		if item.mediaType == "application/x-dtbncx+xml" {
+			return epubNCXTitles(zr, item.path)
+		}
+	}
+	return map[string]string{}, nil
+}
+
+// epubNavTitles reads the links of the toc <nav> in an EPUB 3 navigation
+// document.
+func epubNavTitles(zr *zip.Reader, navPath string) (map[string]string, error) {
+	rc, err := openZipPart(zr, navPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open EPUB navigation: %w", err)
+	}
+	defer rc.Close()
+
+	doc, err := html.Parse(rc)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse EPUB navigation: %w", err)
+	}
+	nav := findFirst(doc, func(n *html.Node) bool {
+		if n.DataAtom != atom.Nav {
+			return false
+		}
+		for _, a := range n.Attr {
+			if (a.Key == "epub:type" || a.Key == "type") && hasProperty(a.Val, "toc") {
+				return true
+			}
+		}
+		return false
+	})
+	if nav == nil {
+		nav = findFirst(doc, func(n *html.Node) bool { return n.DataAtom == atom.Nav })
+	}
+
+	titles := make(map[string]string)
+	if nav == nil {
+		return titles, nil
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			for _, a := range n.Attr {
+				if a.Key != "href" {
+					continue
+				}
+				p := resolveHref(navPath, a.Val)
+				if title := collapseSpace(nodeText(n)); p != "" && title != "" && titles[p] == "" {
+					titles[p] = title
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(nav)
+	return titles, nil
+}
+
+// epubNCXTitles reads the navPoints of an EPUB 2 toc.ncx.
+func epubNCXTitles(zr *zip.Reader, ncxPath string) (map[string]string, error) {
+	rc, err := openZipPart(zr, ncxPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open EPUB NCX: %w", err)
+	}
+	defer rc.Close()
+
+	titles := make(map[string]string)
+	var (
+		label  strings.Builder
+		inText bool
+	)
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not parse EPUB NCX: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "navLabel":
+				label.Reset()
+			case "text":
+				inText = true
+			case "content":
+				// <navLabel> precedes <content> within each navPoint.
+				p := resolveHref(ncxPath, xmlAttr(t, "src"))
+				if title := collapseSpace(label.String()); p != "" && title != "" && titles[p] == "" {
+					titles[p] = title
+				}
+			}
+		case xml.CharData:
+			if inText {
+				label.Write(t)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "text" {
+				inText = false
+			}
+		}
+	}
+	return titles, nil
+}
+
+// resolveHref turns an href found in the part at base into a zip path,
+// dropping any #fragment and undoing percent-encoding.
+func resolveHref(base, href string) string {
+	if i := strings.IndexByte(href, '#'); i >= 0 {
+		href = href[:i]
+	}
+	if href == "" {
+		return ""
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	if strings.HasPrefix(href, "/") {
+		return strings.TrimPrefix(path.Clean(href), "/")
+	}
+	return path.Join(path.Dir(base), href)
+}
+
+// hasProperty reports whether a space-separated property list contains p.
+func hasProperty(list, p string) bool {
+	for _, f := range strings.Fields(list) {
+		if f == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,302 @@
+// internal/utils/extract.go
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Supported document formats, as detected by DetectFormat.
+const (
+	FormatPDF      = "pdf"
+	FormatDOCX     = "docx"
+	FormatPPTX     = "pptx"
+	FormatXLSX     = "xlsx"
+	FormatEPUB     = "epub"
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+	FormatCSV      = "csv"
+	FormatEmail    = "email"
+	FormatMbox     = "mbox"
+	FormatSRT      = "srt"
+	FormatVTT      = "vtt"
+	FormatText     = "text"
+	// FormatZip is a plain zip archive. It has no text of its own; uploads
+	// expand it into separate files.
+	FormatZip = "zip"
+)
+
+// markdownExts are the extensions that mark a plain-text file as Markdown;
+// the content alone can't tell the two apart.
+var markdownExts = map[string]bool{".md": true, ".markdown": true, ".mdown": true, ".mkd": true}
+
+// csvExts mark a plain-text file as delimited data.
+var csvExts = map[string]bool{".csv": true, ".tsv": true}
+
+// legacyTextExts mark text formats that are often saved in a legacy
+// single-byte encoding; a file with one of them is read as text even when
+// it isn't UTF-8 (see DecodeText), or when it starts like XML (a note that
+// opens with an XML prolog or tag).
+var legacyTextExts = map[string]bool{
+	".txt": true, ".text": true, ".md": true, ".markdown": true,
+	".csv": true, ".tsv": true, ".srt": true, ".vtt": true,
+}
+
+// htmlExts let XHTML (sniffed as XML) through as HTML.
+var htmlExts = map[string]bool{".html": true, ".htm": true, ".xhtml": true}
+
+// utf8BOM is the UTF-8 byte order mark.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// sniffLen is how many leading bytes DetectFormat inspects, matching
+// http.DetectContentType.
+const sniffLen = 512
+
+// DetectFormat works out what kind of document lives at path from its
+// content rather than trusting the extension: PDFs by their header, Office
+// files and EPUBs by the parts inside the zip container, HTML by its markup,
+// emails and mailboxes by their headers, subtitles (SRT, WebVTT) by their
+// first cue, and plain text by checking the content is valid UTF-8. The
+// extension is only consulted to tell Markdown and CSV from plain text and
+// XHTML from other XML, and to accept text files in a legacy encoding
+// (e.g. a Latin-1 .txt or .csv). Anything else is rejected.
+func DetectFormat(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("could not open file: %w", err)
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", fmt.Errorf("could not read file: %w", err)
+	}
+	head = head[:n]
+	// DetectContentType reads a BOM as "text/plain"; look past it.
+	head = bytes.TrimPrefix(head, utf8BOM)
+
+	if bytes.HasPrefix(head, []byte("%PDF-")) {
+		return FormatPDF, nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ct := http.DetectContentType(head); {
+	case ct == "application/zip":
+		return detectZipFormat(path)
+	case strings.HasPrefix(ct, "text/html"):
+		return FormatHTML, nil
+	case strings.HasPrefix(ct, "text/xml") && htmlExts[ext]:
+		return FormatHTML, nil
+	case strings.HasPrefix(ct, "text/plain"),
+		// DetectContentType gives up on some valid UTF-8 (e.g. no BOM and a
+		// control character early on); give it a second chance.
+		ct == "application/octet-stream" && looksLikeText(head),
+		ct == "application/octet-stream" && looksLikeLegacyText(head, ext),
+		strings.HasPrefix(ct, "text/xml") && legacyTextExts[ext]:
+		switch {
+		case isMbox(head):
+			return FormatMbox, nil
+		case isEmail(head):
+			return FormatEmail, nil
+		case isVTT(head):
+			return FormatVTT, nil
+		case isSRT(head):
+			return FormatSRT, nil
+		}
+		if markdownExts[ext] {
+			return FormatMarkdown, nil
+		}
+		if csvExts[ext] {
+			return FormatCSV, nil
+		}
+		return FormatText, nil
+	default:
+		return "", fmt.Errorf("unsupported file type %q (%s)", ct, filepath.Ext(path))
+	}
+}
+
+// extContentTypes name the specific media type of files that sniff as a
+// generic zip container or as plain text.
+var extContentTypes = map[string]string{
+	".docx":     "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".pptx":     "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".xlsx":     "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".epub":     "application/epub+zip",
+	".md":       "text/markdown; charset=utf-8",
+	".markdown": "text/markdown; charset=utf-8",
+	".csv":      "text/csv; charset=utf-8",
+	".tsv":      "text/tab-separated-values; charset=utf-8",
+	".srt":      "application/x-subrip",
+	".vtt":      "text/vtt; charset=utf-8",
+	".eml":      "message/rfc822",
+	".mbox":     "application/mbox",
+}
+
+// SniffContentType works out the media type of a file named name from its
+// first sniffLen bytes, and reports whether it is a kind DetectFormat may
+// accept: a PDF, a zip container, HTML (or XHTML) or text (UTF-8, or a
+// legacy encoding for the extensions in legacyTextExts). Zip containers
+// and text get a more specific type from the extension when it names one
+// (e.g. DOCX, Markdown); the full check of the content is left to
+// DetectFormat.
+func SniffContentType(head []byte, name string) (string, bool) {
+	head = bytes.TrimPrefix(head, utf8BOM)
+	if bytes.HasPrefix(head, []byte("%PDF-")) {
+		return "application/pdf", true
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	ct := http.DetectContentType(head)
+	if ct == "application/octet-stream" && (looksLikeText(head) || looksLikeLegacyText(head, ext)) ||
+		strings.HasPrefix(ct, "text/xml") && legacyTextExts[ext] {
+		ct = "text/plain; charset=utf-8"
+	}
+	switch {
+	case ct == "application/zip", strings.HasPrefix(ct, "text/plain"):
+		if specific, ok := extContentTypes[ext]; ok {
+			ct = specific
+		}
+		if ct != "application/zip" && !looksLikeText(head) {
+			ct = strings.Replace(ct, "charset=utf-8", "charset=windows-1252", 1)
+		}
+		return ct, true
+	case strings.HasPrefix(ct, "text/html"):
+		return ct, true
+	case strings.HasPrefix(ct, "text/xml") && htmlExts[ext]:
+		return "application/xhtml+xml", true
+	}
+	return ct, false
+}
+
+// detectZipFormat tells Office Open XML containers apart by their main part
+// and recognizes EPUBs by their container manifest. Any other zip is a plain
+// archive.
+func detectZipFormat(path string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", fmt.Errorf("could not open zip container: %w", err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		switch f.Name {
+		case "word/document.xml":
+			return FormatDOCX, nil
+		case "ppt/presentation.xml":
+			return FormatPPTX, nil
+		case "xl/workbook.xml":
+			return FormatXLSX, nil
+		case "META-INF/container.xml":
+			return FormatEPUB, nil
+		}
+	}
+	return FormatZip, nil
+}
+
+// looksLikeLegacyText reports whether head, from a file named with one of
+// legacyTextExts, can be text in a single-byte encoding: it has no NULs.
+func looksLikeLegacyText(head []byte, ext string) bool {
+	return legacyTextExts[ext] && bytes.IndexByte(head, 0) < 0
+}
+
+// DecodeText returns the text of a file as UTF-8, without a byte order
+// mark. Content that isn't valid UTF-8 is taken to be Windows-1252 (a
+// superset of Latin-1), the usual legacy encoding of such files.
+func DecodeText(b []byte) string {
+	b = bytes.TrimPrefix(b, utf8BOM)
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	if decoded, err := charmap.Windows1252.NewDecoder().Bytes(b); err == nil {
+		return string(decoded)
+	}
+	return strings.ToValidUTF8(string(b), "\uFFFD")
+}
+
+// looksLikeText reports whether head is valid UTF-8 without NUL bytes,
+// allowing for a rune cut off at the end of the sniffed prefix.
+func looksLikeText(head []byte) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+	for i := 0; i < utf8.UTFMax && len(head) > 0; i++ {
+		if utf8.Valid(head) {
+			return true
+		}
+		head = head[:len(head)-1]
+	}
+	return utf8.Valid(head)
+}
+
+// ExtractDocument detects the format of the file at path and returns its
+// text split into sections by heading. Plain text comes back as a single
+// section with an empty heading path; PDFs come back page by page, under
+// their bookmarks if they have any.
+// DOCX and PPTX are converted to Markdown first, so their sections follow
+// document headings and slides respectively. Subtitles come back as timed
+// paragraphs, spreadsheets as one "Header: value" record per row, and
+// emails as one section per message under its subject.
+func ExtractDocument(path string) ([]Section, error) {
+	format, err := DetectFormat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatPDF:
+		return ExtractSectionsFromPDF(path)
+	case FormatHTML:
+		return ExtractSectionsFromHTML(path)
+	case FormatEPUB:
+		return ExtractSectionsFromEPUB(path)
+	case FormatEmail, FormatMbox:
+		return ExtractSectionsFromEmail(path)
+	case FormatSRT, FormatVTT:
+		return ExtractSectionsFromSubtitles(path)
+	case FormatCSV, FormatXLSX:
+		tables, err := ReadTables(path)
+		if err != nil {
+			return nil, err
+		}
+		return tableSections(tables), nil
+	case FormatZip:
+		return nil, fmt.Errorf("zip archive has no text of its own (archives are expanded on upload)")
+	}
+
+	var text string
+	switch format {
+	case FormatDOCX:
+		text, err = ExtractTextFromDOCX(path)
+	case FormatPPTX:
+		text, err = ExtractTextFromPPTX(path)
+	default:
+		var b []byte
+		b, err = os.ReadFile(path)
+		if err != nil {
+			err = fmt.Errorf("text file read error: %w", err)
+		}
+		text = DecodeText(b)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatDOCX, FormatPPTX, FormatMarkdown:
+		return SectionsFromMarkdown(text), nil
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	return []Section{{Text: text}}, nil
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	xmlNote := "<?xml version=\"1.0\"?>\n<note>Remember the milk.</note>\n"
+	tests := []struct {
+		name    string
+		content string
+		want    string // "" means rejected
+	}{
+		{"notes.txt", "Plain notes.\n", FormatText},
+		{"notes.md", "# Title\n\nBody.\n", FormatMarkdown},
+		{"table.csv", "a,b\n1,2\n", FormatCSV},
+		{"legacy.txt", "caf\xe9 na\xefve\n", FormatText},
+		{"xml-note.txt", xmlNote, FormatText},
+		{"xml-note.md", xmlNote, FormatMarkdown},
+		{"tagged.txt", "<note>Remember the milk.</note>\n", FormatText},
+		{"page.xhtml", "<?xml version=\"1.0\"?>\n<html xmlns=\"http://www.w3.org/1999/xhtml\"><body>Hi</body></html>\n", FormatHTML},
+		{"data.xml", xmlNote, ""},
+		{"doc.pdf", "%PDF-1.7\n", FormatPDF},
+		{"subs.srt", "1\n00:00:01,000 --> 00:00:02,000\nHello\n", FormatSRT},
+		{"binary.bin", "\x00\x01\x02\x03\xff\xfe", ""},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := DetectFormat(path)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("DetectFormat = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("DetectFormat = %q, %v, want %q", got, err, tt.want)
+			}
+			if _, ok := SniffContentType([]byte(tt.content), tt.name); !ok {
+				t.Errorf("SniffContentType rejects what DetectFormat accepts")
+			}
+		})
+	}
+}
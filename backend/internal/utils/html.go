@@ -0,0 +1,284 @@
+// internal/utils/html.go
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// ExtractSectionsFromHTML reads an HTML file and returns its readable text
+// split by <h1>–<h6> headings. Navigation, headers, footers, sidebars,
+// scripts and similar boilerplate are dropped; when the page has an
+// <article> or <main> element only that is used.
+func ExtractSectionsFromHTML(path string) ([]Section, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open HTML: %w", err)
+	}
+	defer f.Close()
+
+	doc, err := ParseHTML(f)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Sections, nil
+}
+
+// HTMLDocument is the readable part of an HTML page.
+type HTMLDocument struct {
+	Title    string // <title>, "" if missing
+	Sections []Section
+}
+
+// ParseHTML is ExtractSectionsFromHTML for an already-open reader, also
+// returning the page title. The character set is taken from <meta charset>
+// (or a BOM), defaulting to UTF-8.
+func ParseHTML(r io.Reader) (*HTMLDocument, error) {
+	utf8Reader, err := charset.NewReader(r, "text/html")
+	if err != nil {
+		return nil, fmt.Errorf("could not detect HTML charset: %w", err)
+	}
+	// A UTF-8 BOM selects the encoding but is not stripped by the decoder.
+	br := bufio.NewReader(utf8Reader)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, utf8BOM) {
+		br.Discard(3)
+	}
+	doc, err := html.Parse(br)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse HTML: %w", err)
+	}
+
+	out := &HTMLDocument{}
+	if n := findFirst(doc, func(n *html.Node) bool { return n.DataAtom == atom.Title }); n != nil {
+		out.Title = collapseSpace(nodeText(n))
+	}
+
+	var w htmlWalker
+	w.walk(findMainContent(doc))
+	out.Sections = w.b.result()
+	return out, nil
+}
+
+// boilerplateTags never hold article content.
+var boilerplateTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Footer: true, atom.Aside: true,
+	atom.Form: true, atom.Button: true, atom.Select: true, atom.Iframe: true,
+	atom.Svg: true, atom.Canvas: true, atom.Head: true,
+}
+
+// boilerplateRoles are ARIA landmark roles for page chrome.
+var boilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true,
+	"complementary": true, "search": true, "dialog": true,
+}
+
+// boilerplateHints are class/id words that mark page chrome.
+var boilerplateHints = map[string]bool{
+	"nav": true, "navbar": true, "menu": true, "sidebar": true, "footer": true,
+	"breadcrumb": true, "breadcrumbs": true, "cookie": true,
+	"cookies": true, "banner": true, "advert": true, "ads": true, "share": true,
+	"social": true, "related": true, "newsletter": true, "popup": true, "modal": true,
+}
+
+// blockTags end the current line of text.
+var blockTags = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.Blockquote: true, atom.Pre: true, atom.Ul: true, atom.Ol: true, atom.Li: true,
+	atom.Table: true, atom.Tr: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Figure: true, atom.Figcaption: true, atom.Hr: true, atom.Address: true,
+	atom.Details: true, atom.Summary: true, atom.Body: true,
+}
+
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// isBoilerplate reports whether n (an element) should be skipped entirely.
+func isBoilerplate(n *html.Node) bool {
+	if boilerplateTags[n.DataAtom] {
+		return true
+	}
+	// A site-wide <header> is chrome, but an article's <header> usually
+	// holds its title, so keep headers that contain a heading.
+	if n.DataAtom == atom.Header {
+		return findFirst(n, func(c *html.Node) bool { _, ok := headingLevels[c.DataAtom]; return ok }) == nil
+	}
+	for _, a := range n.Attr {
+		switch a.Key {
+		case "hidden":
+			return true
+		case "aria-hidden":
+			if a.Val == "true" {
+				return true
+			}
+		case "role":
+			if boilerplateRoles[strings.ToLower(a.Val)] {
+				return true
+			}
+		case "class", "id":
+			for _, word := range strings.FieldsFunc(strings.ToLower(a.Val), func(r rune) bool {
+				return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+			}) {
+				if boilerplateHints[word] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// findMainContent picks <article>, then <main> / role=main, then <body>.
+func findMainContent(doc *html.Node) *html.Node {
+	if n := findFirst(doc, func(n *html.Node) bool { return n.DataAtom == atom.Article }); n != nil {
+		return n
+	}
+	if n := findFirst(doc, func(n *html.Node) bool {
+		if n.DataAtom == atom.Main {
+			return true
+		}
+		for _, a := range n.Attr {
+			if a.Key == "role" && a.Val == "main" {
+				return true
+			}
+		}
+		return false
+	}); n != nil {
+		return n
+	}
+	if n := findFirst(doc, func(n *html.Node) bool { return n.DataAtom == atom.Body }); n != nil {
+		return n
+	}
+	return doc
+}
+
+// findFirst does a depth-first search for an element matching pred.
+func findFirst(n *html.Node, pred func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && pred(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findFirst(c, pred); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// nodeText concatenates all text below n.
+func nodeText(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		if n.Type == html.ElementNode && (n.DataAtom == atom.Script || n.DataAtom == atom.Style) {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+// collapseSpace replaces runs of whitespace with single spaces and trims.
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// htmlWalker turns the DOM into sections of plain text.
+type htmlWalker struct {
+	b     sectionBuilder
+	line  strings.Builder
+	inPre int
+}
+
+// endLine moves the pending inline text into the section as one line.
+func (w *htmlWalker) endLine() {
+	text := w.line.String()
+	w.line.Reset()
+	if w.inPre == 0 {
+		text = collapseSpace(text)
+	}
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	w.b.text.WriteString(text + "\n")
+}
+
+func (w *htmlWalker) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.line.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		if isBoilerplate(n) {
+			return
+		}
+		if level, ok := headingLevels[n.DataAtom]; ok {
+			w.endLine()
+			if title := collapseSpace(nodeText(n)); title != "" {
+				w.b.heading(level, title)
+			}
+			return
+		}
+		switch n.DataAtom {
+		case atom.Br:
+			w.endLine()
+			return
+		case atom.Img:
+			return
+		}
+	}
+
+	block := n.Type == html.ElementNode && blockTags[n.DataAtom]
+	if block {
+		w.endLine()
+	}
+	switch n.DataAtom {
+	case atom.Li:
+		w.line.WriteString("- ")
+	case atom.Pre:
+		w.inPre++
+	case atom.Td, atom.Th:
+		if hasPrevElement(n) {
+			w.line.WriteString(" | ")
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+
+	if n.DataAtom == atom.Pre {
+		w.inPre--
+	}
+	if block {
+		w.endLine()
+		if n.DataAtom == atom.P || n.DataAtom == atom.Pre || n.DataAtom == atom.Blockquote {
+			w.b.text.WriteString("\n")
+		}
+	}
+}
+
+// hasPrevElement reports whether n has an element sibling before it.
+func hasPrevElement(n *html.Node) bool {
+	for p := n.PrevSibling; p != nil; p = p.PrevSibling {
+		if p.Type == html.ElementNode {
+			return true
+		}
+	}
+	return false
+}
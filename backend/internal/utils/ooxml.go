@@ -0,0 +1,90 @@
+// internal/utils/ooxml.go
+package utils
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Namespaces used by the Office Open XML parts we read.
+const (
+	nsWordML       = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	nsDrawingML    = "http://schemas.openxmlformats.org/drawingml/2006/main"
+	nsPresentML    = "http://schemas.openxmlformats.org/presentationml/2006/main"
+	nsOfficeRel    = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	nsMarkupCompat = "http://schemas.openxmlformats.org/markup-compatibility/2006"
+)
+
+// ooxmlRel is one <Relationship> from a .rels part.
+type ooxmlRel struct {
+	ID     string `xml:"Id,attr"`
+	Type   string `xml:"Type,attr"`
+	Target string `xml:"Target,attr"`
+}
+
+// openZipPart opens the named part of an OOXML package.
+func openZipPart(zr *zip.Reader, name string) (io.ReadCloser, error) {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return f.Open()
+		}
+	}
+	return nil, fmt.Errorf("part %s not found", name)
+}
+
+// hasZipPart reports whether the package contains the named part.
+func hasZipPart(zr *zip.Reader, name string) bool {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readRels parses the relationships of part (e.g. "ppt/presentation.xml" →
+// "ppt/_rels/presentation.xml.rels"), resolving targets to package paths.
+// A part without relationships yields an empty map.
+func readRels(zr *zip.Reader, part string) (map[string]ooxmlRel, error) {
+	relsPath := path.Join(path.Dir(part), "_rels", path.Base(part)+".rels")
+	out := make(map[string]ooxmlRel)
+	if !hasZipPart(zr, relsPath) {
+		return out, nil
+	}
+
+	rc, err := openZipPart(zr, relsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var doc struct {
+		Rels []ooxmlRel `xml:"Relationship"`
+	}
+	if err := xml.NewDecoder(rc).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", relsPath, err)
+	}
+	for _, r := range doc.Rels {
+		if strings.HasPrefix(r.Target, "/") {
+			r.Target = strings.TrimPrefix(r.Target, "/")
+		} else {
+			r.Target = path.Join(path.Dir(part), r.Target)
+		}
+		out[r.ID] = r
+	}
+	return out, nil
+}
+
+// xmlAttr returns the value of the attribute with the given local name.
+func xmlAttr(se xml.StartElement, local string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -11,28 +11,68 @@ import (
 	"github.com/ledongthuc/pdf"
 )
 
-// ExtractTextFromPDF opens a PDF file at `path` and returns its full text.
+const (
+	// maxOutlineItems and maxOutlineDepth bound the outline walk, which a
+	// malformed PDF could otherwise send round a cycle.
+	maxOutlineItems = 5000
+	maxOutlineDepth = 6
+)
+
+// PageRange is the pages (1-based, inclusive) some text came from.
+type PageRange struct {
+	Start, End int
+}
+
+// FormatPages renders a page range as "p. 42" or "p. 42–43".
+func FormatPages(start, end int) string {
+	if end <= start {
+		return fmt.Sprintf("p. %d", start)
+	}
+	return fmt.Sprintf("p. %d–%d", start, end)
+}
+
+// pdfHeading is an outline (bookmark) entry and the page it points at.
+type pdfHeading struct {
+	path []string
+	page int
+}
+
+// ExtractSectionsFromPDF opens a PDF file at `path` and returns its text
+// page by page, each section carrying its page number. When the PDF has an
+// outline, pages are filed under the bookmark titles that cover them, and a
+// page where a new bookmark starts is split at the bookmark's title if it
+// can be found in the text.
 // If the file isn’t a valid PDF, it returns an error.
-func ExtractTextFromPDF(path string) (string, error) {
+func ExtractSectionsFromPDF(path string) ([]Section, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return "", fmt.Errorf("could not open PDF: %w", err)
+		return nil, fmt.Errorf("could not open PDF: %w", err)
 	}
 	defer f.Close()
 
 	// ledongthuc/pdf requires reading the entire file into memory
 	buf := new(bytes.Buffer)
 	if _, err := io.Copy(buf, f); err != nil {
-		return "", fmt.Errorf("could not read PDF into buffer: %w", err)
+		return nil, fmt.Errorf("could not read PDF into buffer: %w", err)
 	}
 
 	// Use pdf.NewReader on the buffer
 	reader, err := pdf.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
 	if err != nil {
-		return "", fmt.Errorf("pdf.NewReader error: %w", err)
+		return nil, fmt.Errorf("pdf.NewReader error: %w", err)
 	}
 
-	var output strings.Builder
+	headings := pdfOutline(reader)
+	var (
+		out     []Section
+		current []string // heading path of the text being read
+		next    int      // index of the first heading not yet reached
+	)
+	emit := func(text string, page int) {
+		if text = strings.TrimSpace(text); text != "" {
+			out = append(out, Section{HeadingPath: current, Text: text, Pages: &PageRange{Start: page, End: page}})
+		}
+	}
 	// Iterate over each page
 	numPages := reader.NumPage()
 	for i := 1; i <= numPages; i++ {
@@ -42,11 +82,116 @@ func ExtractTextFromPDF(path string) (string, error) {
 		}
 		text, err := page.GetPlainText(nil)
 		if err != nil {
-			return "", fmt.Errorf("could not extract text from page %d: %w", i, err)
+			return nil, fmt.Errorf("could not extract text from page %d: %w", i, err)
+		}
+
+		for next < len(headings) && headings[next].page <= i {
+			h := headings[next]
+			next++
+			if h.page == i {
+				title := h.path[len(h.path)-1]
+				if at := strings.Index(text, title); at > 0 {
+					emit(text[:at], i)
+					text = text[at:]
+				}
+			}
+			current = h.path
+		}
+		emit(text, i)
+	}
+	return out, nil
+}
+
+// pdfOutline flattens the document outline into headings in reading order,
+// leaving out entries whose destination can't be resolved to a page.
+func pdfOutline(r *pdf.Reader) []pdfHeading {
+	root := r.Trailer().Key("Root")
+	first := root.Key("Outlines").Key("First")
+	if first.Kind() != pdf.Dict {
+		return nil
+	}
+
+	// Pages are matched by their dictionary, whose text includes references
+	// unique to each page (its content streams).
+	pageNums := make(map[string]int)
+	for i := 1; i <= r.NumPage(); i++ {
+		if p := r.Page(i); !p.V.IsNull() {
+			if _, ok := pageNums[p.V.String()]; !ok {
+				pageNums[p.V.String()] = i
+			}
 		}
-		output.WriteString(text)
-		output.WriteString("\n\n")
 	}
 
-	return output.String(), nil
+	var (
+		out   []pdfHeading
+		items int
+		walk  func(item pdf.Value, parent []string, depth int)
+	)
+	walk = func(item pdf.Value, parent []string, depth int) {
+		for ; item.Kind() == pdf.Dict && items < maxOutlineItems; item = item.Key("Next") {
+			items++
+			path := parent
+			if title := collapseSpace(item.Key("Title").Text()); title != "" {
+				path = append(append([]string(nil), parent...), title)
+			}
+			if page := pdfDestPage(root, item, pageNums); page > 0 && len(path) > 0 {
+				out = append(out, pdfHeading{path: path, page: page})
+			}
+			if depth < maxOutlineDepth {
+				walk(item.Key("First"), path, depth+1)
+			}
+		}
+	}
+	walk(first, nil, 1)
+	return out
+}
+
+// pdfDestPage returns the page an outline item jumps to, or 0. The
+// destination may be given directly or through a GoTo action, and either
+// explicitly or by name.
+func pdfDestPage(root, item pdf.Value, pageNums map[string]int) int {
+	dest := item.Key("Dest")
+	if dest.IsNull() {
+		if action := item.Key("A"); action.Key("S").Name() == "GoTo" {
+			dest = action.Key("D")
+		}
+	}
+	switch dest.Kind() {
+	case pdf.Name:
+		dest = root.Key("Dests").Key(dest.Name())
+	case pdf.String:
+		dest = pdfNameTreeLookup(root.Key("Names").Key("Dests"), dest.RawString(), 0)
+	}
+	if dest.Kind() == pdf.Dict {
+		dest = dest.Key("D")
+	}
+	if dest.Kind() != pdf.Array || dest.Len() == 0 {
+		return 0
+	}
+	return pageNums[dest.Index(0).String()]
+}
+
+// pdfNameTreeLookup finds key in a PDF name tree.
+func pdfNameTreeLookup(node pdf.Value, key string, depth int) pdf.Value {
+	if node.Kind() != pdf.Dict || depth > maxOutlineDepth*2 {
+		return pdf.Value{}
+	}
+	names := node.Key("Names")
+	for i := 0; i+1 < names.Len(); i += 2 {
+		if names.Index(i).RawString() == key {
+			return names.Index(i + 1)
+		}
+	}
+	kids := node.Key("Kids")
+	for i := 0; i < kids.Len(); i++ {
+		kid := kids.Index(i)
+		if limits := kid.Key("Limits"); limits.Len() == 2 &&
+			(key < limits.Index(0).RawString() || key > limits.Index(1).RawString()) {
+			continue
+		}
+		if v := pdfNameTreeLookup(kid, key, depth+1); !v.IsNull() {
+			return v
+		}
+	}
+	return pdf.Value{}
 }
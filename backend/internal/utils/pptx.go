@@ -0,0 +1,246 @@
+// internal/utils/pptx.go
+package utils
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Relationship type of a slide's speaker notes.
+const relTypeNotesSlide = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/notesSlide"
+
+// ExtractTextFromPPTX returns the text of a .pptx file slide by slide, in
+// presentation order. Each slide starts with a "# Slide N: Title" heading,
+// followed by its body text and, when present, its speaker notes.
+func ExtractTextFromPPTX(path string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", fmt.Errorf("could not open PPTX: %w", err)
+	}
+	defer zr.Close()
+
+	slides, err := pptxSlideOrder(&zr.Reader)
+	if err != nil {
+		return "", err
+	}
+
+	var out []string
+	for i, slidePath := range slides {
+		shapes, err := readPPTXShapes(&zr.Reader, slidePath)
+		if err != nil {
+			return "", fmt.Errorf("could not read slide %d: %w", i+1, err)
+		}
+
+		var title string
+		var body []string
+		for _, sh := range shapes {
+			if title == "" && (sh.placeholder == "title" || sh.placeholder == "ctrTitle") {
+				title = strings.Join(sh.paragraphs, " ")
+				continue
+			}
+			body = append(body, sh.paragraphs...)
+		}
+
+		heading := "# Slide " + strconv.Itoa(i+1)
+		if title != "" {
+			heading += ": " + title
+		}
+		section := []string{heading}
+		if len(body) > 0 {
+			section = append(section, strings.Join(body, "\n"))
+		}
+
+		notes, err := pptxNotes(&zr.Reader, slidePath)
+		if err != nil {
+			return "", fmt.Errorf("could not read notes for slide %d: %w", i+1, err)
+		}
+		if notes != "" {
+			section = append(section, "Speaker notes:\n"+notes)
+		}
+		out = append(out, strings.Join(section, "\n\n"))
+	}
+	return strings.Join(out, "\n\n"), nil
+}
+
+// pptxSlideOrder returns slide part paths in the order listed by
+// <p:sldIdLst> in ppt/presentation.xml (file names don't reflect reordering).
+func pptxSlideOrder(zr *zip.Reader) ([]string, error) {
+	rels, err := readRels(zr, "ppt/presentation.xml")
+	if err != nil {
+		return nil, err
+	}
+	rc, err := openZipPart(zr, "ppt/presentation.xml")
+	if err != nil {
+		return nil, fmt.Errorf("could not open PPTX presentation: %w", err)
+	}
+	defer rc.Close()
+
+	var slides []string
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not parse PPTX presentation: %w", err)
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Space != nsPresentML || se.Name.Local != "sldId" {
+			continue
+		}
+		for _, a := range se.Attr {
+			if a.Name.Space == nsOfficeRel && a.Name.Local == "id" {
+				if rel, ok := rels[a.Value]; ok {
+					slides = append(slides, rel.Target)
+				}
+			}
+		}
+	}
+	return slides, nil
+}
+
+// pptxNotes returns the speaker notes attached to a slide, or "".
+func pptxNotes(zr *zip.Reader, slidePath string) (string, error) {
+	rels, err := readRels(zr, slidePath)
+	if err != nil {
+		return "", err
+	}
+	for _, rel := range rels {
+		if rel.Type != relTypeNotesSlide {
+			continue
+		}
+		shapes, err := readPPTXShapes(zr, rel.Target)
+		if err != nil {
+			return "", err
+		}
+		var lines []string
+		for _, sh := range shapes {
+			// Notes pages repeat the slide image, number, header, etc.
+			switch sh.placeholder {
+			case "sldImg", "sldNum", "hdr", "ftr", "dt":
+				continue
+			}
+			lines = append(lines, sh.paragraphs...)
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+	return "", nil
+}
+
+// pptxShape is the text of one shape (or table) on a slide.
+type pptxShape struct {
+	placeholder string // <p:ph type="…">, "" when not a placeholder
+	paragraphs  []string
+}
+
+// readPPTXShapes streams a slide (or notes) part and returns the text of
+// each shape. Bulleted paragraphs keep their indent level as "- " items and
+// tables come back as "| a | b |" rows.
+func readPPTXShapes(zr *zip.Reader, part string) ([]pptxShape, error) {
+	rc, err := openZipPart(zr, part)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var (
+		shapes []pptxShape
+		cur    *pptxShape
+		para   strings.Builder
+		level  = -1
+		inText bool
+		inPara bool
+		row    []string
+		cell   []string
+		tables int
+	)
+
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == nsPresentML && (t.Name.Local == "sp" || t.Name.Local == "graphicFrame"):
+				cur = &pptxShape{}
+			case t.Name.Space == nsPresentML && t.Name.Local == "ph":
+				if cur != nil {
+					cur.placeholder = xmlAttr(t, "type")
+				}
+			case t.Name.Space == nsDrawingML && t.Name.Local == "tbl":
+				tables++
+			case t.Name.Space == nsDrawingML && t.Name.Local == "tr":
+				row = nil
+			case t.Name.Space == nsDrawingML && t.Name.Local == "tc":
+				cell = nil
+			case t.Name.Space == nsDrawingML && t.Name.Local == "p":
+				para.Reset()
+				level = -1
+				inPara = true
+			case t.Name.Space == nsDrawingML && t.Name.Local == "pPr":
+				if v := xmlAttr(t, "lvl"); v != "" {
+					if lvl, err := strconv.Atoi(v); err == nil {
+						level = lvl
+					}
+				}
+			case t.Name.Space == nsDrawingML && t.Name.Local == "t":
+				inText = true
+			case t.Name.Space == nsDrawingML && t.Name.Local == "br":
+				if inPara {
+					para.WriteByte('\n')
+				}
+			}
+
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+
+		case xml.EndElement:
+			switch {
+			case t.Name.Space == nsDrawingML && t.Name.Local == "t":
+				inText = false
+			case t.Name.Space == nsDrawingML && t.Name.Local == "p":
+				inPara = false
+				text := strings.TrimSpace(para.String())
+				if text == "" || cur == nil {
+					continue
+				}
+				if tables > 0 {
+					cell = append(cell, text)
+					continue
+				}
+				if level > 0 {
+					text = strings.Repeat("  ", level-1) + "- " + text
+				}
+				cur.paragraphs = append(cur.paragraphs, text)
+			case t.Name.Space == nsDrawingML && t.Name.Local == "tc":
+				row = append(row, strings.Join(cell, " "))
+			case t.Name.Space == nsDrawingML && t.Name.Local == "tr":
+				if cur != nil {
+					cur.paragraphs = append(cur.paragraphs, "| "+strings.Join(row, " | ")+" |")
+				}
+			case t.Name.Space == nsDrawingML && t.Name.Local == "tbl":
+				tables--
+			case t.Name.Space == nsPresentML && (t.Name.Local == "sp" || t.Name.Local == "graphicFrame"):
+				if cur != nil && len(cur.paragraphs) > 0 {
+					shapes = append(shapes, *cur)
+				}
+				cur = nil
+			}
+		}
+	}
+	return shapes, nil
+}
@@ -0,0 +1,242 @@
+// internal/utils/sections.go
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// HeadingPathSeparator joins a heading path for display and storage,
+// e.g. "Chapter 3 > Cell Division".
+const HeadingPathSeparator = " > "
+
+// TimeSpan is the stretch of a recording some text was spoken in, as
+// offsets from the start.
+type TimeSpan struct {
+	Start, End time.Duration
+}
+
+// Section is a run of text that sits under the same headings.
+type Section struct {
+	HeadingPath []string
+	Text        string
+	Span        *TimeSpan  // set for transcripts
+	Pages       *PageRange // set for PDFs
+	Break       bool       // start a new chunk here even under the same headings (e.g. a new email)
+}
+
+// Chunk is one piece of text ready to be embedded, with the structural
+// position it came from.
+type Chunk struct {
+	Text        string
+	Tokens      int // in TokenEncoding
+	HeadingPath []string
+	Span        *TimeSpan
+	Pages       *PageRange
+}
+
+// JoinHeadingPath renders a heading path with HeadingPathSeparator.
+func JoinHeadingPath(path []string) string {
+	return strings.Join(path, HeadingPathSeparator)
+}
+
+// headingTracker keeps the current heading path as headings of various
+// levels (1–6) are encountered.
+type headingTracker struct {
+	levels [6]string
+}
+
+// push records a heading at level (1-based), clearing any deeper headings.
+func (h *headingTracker) push(level int, title string) {
+	if level < 1 {
+		level = 1
+	}
+	if level > len(h.levels) {
+		level = len(h.levels)
+	}
+	h.levels[level-1] = title
+	for i := level; i < len(h.levels); i++ {
+		h.levels[i] = ""
+	}
+}
+
+// path returns the non-empty headings from outermost to innermost.
+func (h *headingTracker) path() []string {
+	var out []string
+	for _, l := range h.levels {
+		if l != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// sectionBuilder accumulates text and emits a Section whenever the heading
+// path changes.
+type sectionBuilder struct {
+	headings headingTracker
+	text     strings.Builder
+	sections []Section
+}
+
+func (b *sectionBuilder) heading(level int, title string) {
+	b.flush()
+	b.headings.push(level, title)
+}
+
+func (b *sectionBuilder) flush() {
+	text := strings.TrimSpace(b.text.String())
+	b.text.Reset()
+	if text == "" {
+		return
+	}
+	b.sections = append(b.sections, Section{HeadingPath: b.headings.path(), Text: text})
+}
+
+func (b *sectionBuilder) result() []Section {
+	b.flush()
+	return b.sections
+}
+
+var (
+	atxHeading      = regexp.MustCompile(`^ {0,3}(#{1,6})[ \t]+(.*?)(?:[ \t]+#+)?[ \t]*$`)
+	setextUnderline = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	codeFence       = regexp.MustCompile("^ {0,3}(```|~~~)")
+)
+
+// SectionsFromMarkdown splits Markdown into sections along its ATX ("## x")
+// and setext (underlined) headings. Heading-like lines inside fenced code
+// blocks are left alone, and a leading YAML front matter block is dropped.
+func SectionsFromMarkdown(text string) []Section {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	lines = stripFrontMatter(lines)
+
+	var b sectionBuilder
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if m := codeFence.FindStringSubmatch(line); m != nil {
+			switch {
+			case fence == "":
+				fence = m[1]
+			case fence == m[1]:
+				fence = ""
+			}
+			b.text.WriteString(line + "\n")
+			continue
+		}
+		if fence != "" {
+			b.text.WriteString(line + "\n")
+			continue
+		}
+
+		if m := atxHeading.FindStringSubmatch(line); m != nil {
+			b.heading(len(m[1]), strings.TrimSpace(m[2]))
+			continue
+		}
+		if strings.TrimSpace(line) != "" && i+1 < len(lines) && !strings.HasPrefix(strings.TrimSpace(line), "- ") {
+			if m := setextUnderline.FindStringSubmatch(lines[i+1]); m != nil {
+				level := 2
+				if m[1][0] == '=' {
+					level = 1
+				}
+				b.heading(level, strings.TrimSpace(line))
+				i++
+				continue
+			}
+		}
+		b.text.WriteString(line + "\n")
+	}
+	return b.result()
+}
+
+// stripFrontMatter drops a "---" delimited YAML block at the top of a note.
+func stripFrontMatter(lines []string) []string {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return lines
+	}
+	for i := 1; i < len(lines); i++ {
+		if t := strings.TrimSpace(lines[i]); t == "---" || t == "..." {
+			return lines[i+1:]
+		}
+	}
+	return lines
+}
+
+// ChunkSections packs sections into chunks of at most opts.MaxTokens.
+// Consecutive sections under the same heading path are merged (timed
+// sections only with other timed ones, widening the time span, and paged
+// ones only with other paged ones, widening the page range); a section
+// that is too large on its own is split with ChunkText, with overlap.
+// Chunks never span headings.
+func ChunkSections(sections []Section, opts ChunkOptions) []Chunk {
+	var out []Chunk
+	var cur *Chunk
+	for _, s := range sections {
+		n := CountTokens(s.Text)
+		if cur != nil && !s.Break && samePath(cur.HeadingPath, s.HeadingPath) && (cur.Span == nil) == (s.Span == nil) &&
+			(cur.Pages == nil) == (s.Pages == nil) && cur.Tokens+1+n <= opts.MaxTokens {
+			cur.Text += "\n\n" + s.Text
+			cur.Tokens += 1 + n
+			if s.Span != nil {
+				cur.Span.End = s.Span.End
+			}
+			if s.Pages != nil {
+				cur.Pages.End = s.Pages.End
+			}
+			continue
+		}
+		if cur != nil {
+			out = append(out, cur.recount())
+			cur = nil
+		}
+		if n > opts.MaxTokens {
+			for _, piece := range ChunkText(s.Text, opts) {
+				out = append(out, Chunk{Text: piece, Tokens: CountTokens(piece), HeadingPath: s.HeadingPath, Span: copySpan(s.Span), Pages: copyPages(s.Pages)})
+			}
+			continue
+		}
+		cur = &Chunk{Text: s.Text, Tokens: n, HeadingPath: s.HeadingPath, Span: copySpan(s.Span), Pages: copyPages(s.Pages)}
+	}
+	if cur != nil {
+		out = append(out, cur.recount())
+	}
+	return out
+}
+
+// recount replaces the running estimate of a merged chunk's size (which
+// counts each join as one token) with its exact token count.
+func (c *Chunk) recount() Chunk {
+	c.Tokens = CountTokens(c.Text)
+	return *c
+}
+
+func copySpan(s *TimeSpan) *TimeSpan {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	return &c
+}
+
+func copyPages(p *PageRange) *PageRange {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	return &c
+}
+
+func samePath(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,236 @@
+// internal/utils/subtitles.go
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// paragraphTargetChars is the length at which a transcript paragraph
+	// ends at the next sentence boundary.
+	paragraphTargetChars = 600
+	// paragraphMaxChars ends a paragraph regardless of punctuation; it stays
+	// well below the chunk size so chunks can always break between cues.
+	paragraphMaxChars = 1200
+	// paragraphGap is a pause between cues long enough to start a new paragraph.
+	paragraphGap = 3 * time.Second
+)
+
+// cue is one timed caption.
+type cue struct {
+	start, end time.Duration
+	text       string
+}
+
+var (
+	// cueTiming matches "00:01:02,500 --> 00:01:04,000" (SRT) and
+	// "01:02.500 --> 01:04.000 align:start" (WebVTT, hours optional).
+	cueTiming = regexp.MustCompile(`^\s*((?:\d+:)?\d{1,2}:\d{2}[,.]\d{1,3})\s*-->\s*((?:\d+:)?\d{1,2}:\d{2}[,.]\d{1,3})`)
+	// srtStart recognizes an SRT file by its first cue.
+	srtStart = regexp.MustCompile(`^\s*\d+\s*\r?\n\s*(?:\d+:)?\d{1,2}:\d{2}[,.]\d{1,3}\s*-->`)
+	// cueMarkup matches WebVTT tags (<v Speaker>, <c.x>, <00:01.000>, …),
+	// HTML-ish tags in SRT and ASS override blocks like {\an8}.
+	cueMarkup = regexp.MustCompile(`<[^>]*>|\{\\[^}]*\}`)
+	vttVoice  = regexp.MustCompile(`^<v(?:\.[^\s>]*)?\s+([^>]+)>`)
+)
+
+// isVTT and isSRT recognize subtitle files by their content.
+func isVTT(head []byte) bool {
+	return strings.HasPrefix(string(head), "WEBVTT")
+}
+
+func isSRT(head []byte) bool {
+	return srtStart.Match(head)
+}
+
+// ExtractSectionsFromSubtitles parses an .srt or .vtt file and returns its
+// cues merged into paragraphs. Each paragraph carries the time span of the
+// cues it was built from, and paragraphs never split a cue.
+func ExtractSectionsFromSubtitles(path string) ([]Section, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open subtitles: %w", err)
+	}
+
+	cues, err := parseCues(bufio.NewScanner(strings.NewReader(DecodeText(b))))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse subtitles: %w", err)
+	}
+	return cueParagraphs(cues), nil
+}
+
+// parseCues reads SRT or WebVTT cues. The two formats share the same shape
+// (optional identifier, timing line, text lines, blank line); WebVTT's
+// header, NOTE, STYLE and REGION blocks are skipped.
+func parseCues(sc *bufio.Scanner) ([]cue, error) {
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+
+	var (
+		cues    []cue
+		cur     *cue
+		lines   []string
+		skip    bool // inside a block that isn't a cue
+		lastOut string
+	)
+	finish := func() {
+		if cur != nil {
+			var kept []string
+			for _, l := range lines {
+				// Rolling captions repeat the previous line before adding a new one.
+				if l == lastOut {
+					continue
+				}
+				kept = append(kept, l)
+				lastOut = l
+			}
+			if len(kept) > 0 {
+				cur.text = strings.Join(kept, " ")
+				cues = append(cues, *cur)
+			}
+		}
+		cur, lines, skip = nil, nil, false
+	}
+
+	first := true
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+			if strings.HasPrefix(line, "WEBVTT") {
+				skip = true
+				continue
+			}
+		}
+		if line == "" {
+			finish()
+			continue
+		}
+		if skip {
+			continue
+		}
+		if cur == nil {
+			if m := cueTiming.FindStringSubmatch(line); m != nil {
+				start, err := parseCueTime(m[1])
+				if err != nil {
+					return nil, err
+				}
+				end, err := parseCueTime(m[2])
+				if err != nil {
+					return nil, err
+				}
+				cur = &cue{start: start, end: end}
+				continue
+			}
+			if strings.HasPrefix(line, "NOTE") || line == "STYLE" || line == "REGION" {
+				skip = true
+			}
+			// Otherwise a cue identifier (SRT index or WebVTT id).
+			continue
+		}
+		if text := cleanCueLine(line); text != "" {
+			lines = append(lines, text)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	finish()
+	return cues, nil
+}
+
+// cleanCueLine strips markup from a caption line, turning a WebVTT voice
+// span into a "Speaker: " prefix.
+func cleanCueLine(line string) string {
+	speaker := ""
+	if m := vttVoice.FindStringSubmatch(line); m != nil {
+		speaker = strings.TrimSpace(m[1]) + ": "
+	}
+	text := cueMarkup.ReplaceAllString(line, "")
+	text = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&nbsp;", " ").Replace(text)
+	text = collapseSpace(text)
+	if text == "" {
+		return ""
+	}
+	return speaker + text
+}
+
+// parseCueTime parses "hh:mm:ss,mmm", "mm:ss.mmm" and similar.
+func parseCueTime(s string) (time.Duration, error) {
+	s = strings.Replace(s, ",", ".", 1)
+	main, frac, _ := strings.Cut(s, ".")
+	parts := strings.Split(main, ":")
+	var total time.Duration
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return 0, fmt.Errorf("bad timestamp %q", s)
+		}
+		total = total*60 + time.Duration(n)*time.Second
+	}
+	for len(frac) < 3 {
+		frac += "0"
+	}
+	ms, err := strconv.Atoi(frac[:3])
+	if err != nil {
+		return 0, fmt.Errorf("bad timestamp %q", s)
+	}
+	return total + time.Duration(ms)*time.Millisecond, nil
+}
+
+// cueParagraphs merges consecutive cues into paragraphs, breaking after a
+// sentence once a paragraph is long enough, before a long pause, or when a
+// paragraph would grow past paragraphMaxChars.
+func cueParagraphs(cues []cue) []Section {
+	var out []Section
+	var text strings.Builder
+	var span TimeSpan
+	flush := func() {
+		if text.Len() > 0 {
+			out = append(out, Section{Text: text.String(), Span: &TimeSpan{Start: span.Start, End: span.End}})
+		}
+		text.Reset()
+	}
+
+	for i, c := range cues {
+		if text.Len() > 0 {
+			gap := c.start - cues[i-1].end
+			if gap >= paragraphGap || text.Len()+1+len(c.text) > paragraphMaxChars {
+				flush()
+			}
+		}
+		if text.Len() == 0 {
+			span.Start = c.start
+		} else {
+			text.WriteByte(' ')
+		}
+		text.WriteString(c.text)
+		span.End = c.end
+		if text.Len() >= paragraphTargetChars && endsSentence(c.text) {
+			flush()
+		}
+	}
+	flush()
+	return out
+}
+
+func endsSentence(s string) bool {
+	s = strings.TrimRight(s, `"')]”’`)
+	return strings.HasSuffix(s, ".") || strings.HasSuffix(s, "?") || strings.HasSuffix(s, "!")
+}
+
+// FormatTimestamp renders an offset as "m:ss", or "h:mm:ss" from an hour on.
+func FormatTimestamp(d time.Duration) string {
+	secs := int64(d / time.Second)
+	h, m, s := secs/3600, secs/60%60, secs%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
@@ -0,0 +1,151 @@
+// internal/utils/table.go
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Table is a spreadsheet (or one sheet of a workbook) whose first
+// non-empty row is a header.
+type Table struct {
+	Name   string // sheet name; "" for CSV
+	Header []string
+	Rows   [][]string
+}
+
+// Column returns the index of the column with the given header
+// (case-insensitive, surrounding spaces ignored), or -1.
+func (t *Table) Column(name string) int {
+	name = strings.TrimSpace(name)
+	for i, h := range t.Header {
+		if strings.EqualFold(strings.TrimSpace(h), name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// newTable splits raw rows into a header and data rows, dropping empty rows
+// and naming any unnamed header columns "Column N".
+func newTable(name string, raw [][]string) *Table {
+	t := &Table{Name: name}
+	for _, row := range raw {
+		empty := true
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+			if row[i] != "" {
+				empty = false
+			}
+		}
+		if empty {
+			continue
+		}
+		if t.Header == nil {
+			t.Header = row
+			continue
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	for i, h := range t.Header {
+		if h == "" {
+			t.Header[i] = fmt.Sprintf("Column %d", i+1)
+		}
+	}
+	return t
+}
+
+// Sections renders each row as a "Header: value" record (empty cells are
+// left out). A row is never split across sections, so chunking the result
+// with ChunkSections packs whole rows into each chunk. Workbook sheets put
+// their name in the heading path.
+func (t *Table) Sections() []Section {
+	var path []string
+	if t.Name != "" {
+		path = []string{t.Name}
+	}
+	var out []Section
+	for _, row := range t.Rows {
+		var lines []string
+		for i, v := range row {
+			if v == "" {
+				continue
+			}
+			header := fmt.Sprintf("Column %d", i+1)
+			if i < len(t.Header) {
+				header = t.Header[i]
+			}
+			lines = append(lines, header+": "+v)
+		}
+		if len(lines) > 0 {
+			out = append(out, Section{HeadingPath: path, Text: strings.Join(lines, "\n")})
+		}
+	}
+	return out
+}
+
+// ReadCSV parses a CSV (or TSV / semicolon-separated) file. The delimiter
+// is guessed from the first line.
+func ReadCSV(path string) (*Table, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open CSV: %w", err)
+	}
+	b = []byte(DecodeText(b))
+
+	r := csv.NewReader(bytes.NewReader(b))
+	r.Comma = guessDelimiter(b)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	raw, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse CSV: %w", err)
+	}
+	return newTable("", raw), nil
+}
+
+// guessDelimiter picks whichever of , ; \t and | is most common on the
+// first line.
+func guessDelimiter(b []byte) rune {
+	line, _ := bufio.NewReader(bytes.NewReader(b)).ReadString('\n')
+	best, bestCount := ',', 0
+	for _, d := range []rune{',', ';', '\t', '|'} {
+		if n := strings.Count(line, string(d)); n > bestCount {
+			best, bestCount = d, n
+		}
+	}
+	return best
+}
+
+// ReadTables reads every table in a CSV or XLSX file.
+func ReadTables(path string) ([]*Table, error) {
+	format, err := DetectFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case FormatCSV:
+		t, err := ReadCSV(path)
+		if err != nil {
+			return nil, err
+		}
+		return []*Table{t}, nil
+	case FormatXLSX:
+		return ReadXLSX(path)
+	default:
+		return nil, fmt.Errorf("not a spreadsheet (%s)", format)
+	}
+}
+
+// tableSections renders every table of a spreadsheet as sections.
+func tableSections(tables []*Table) []Section {
+	var out []Section
+	for _, t := range tables {
+		out = append(out, t.Sections()...)
+	}
+	return out
+}
@@ -0,0 +1,38 @@
+// internal/utils/tokens.go
+package utils
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// TokenEncoding is the tokenizer of the embedding model (text-embedding-ada-002).
+const TokenEncoding = "cl100k_base"
+
+var (
+	encodingOnce sync.Once
+	encoding     *tiktoken.Tiktoken
+)
+
+// tokenizer returns the shared TokenEncoding tokenizer. Its BPE ranks are
+// embedded in the binary, so nothing is downloaded at runtime.
+func tokenizer() *tiktoken.Tiktoken {
+	encodingOnce.Do(func() {
+		tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+		enc, err := tiktoken.GetEncoding(TokenEncoding)
+		if err != nil {
+			// The ranks are compiled in; this only fails if the build is broken.
+			panic(fmt.Sprintf("load %s tokenizer: %v", TokenEncoding, err))
+		}
+		encoding = enc
+	})
+	return encoding
+}
+
+// CountTokens returns how many tokens the embedding model sees in s.
+func CountTokens(s string) int {
+	return len(tokenizer().EncodeOrdinary(s))
+}
@@ -0,0 +1,229 @@
+// internal/utils/xlsx.go
+package utils
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ReadXLSX returns one Table per worksheet of an .xlsx workbook, in tab
+// order. Cells are read as displayed text where the file stores it (shared
+// and inline strings, booleans, formula results); numbers, including
+// dates, come back as their stored value.
+func ReadXLSX(path string) ([]*Table, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open XLSX: %w", err)
+	}
+	defer zr.Close()
+
+	shared, err := xlsxSharedStrings(&zr.Reader)
+	if err != nil {
+		return nil, err
+	}
+	rels, err := readRels(&zr.Reader, "xl/workbook.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := openZipPart(&zr.Reader, "xl/workbook.xml")
+	if err != nil {
+		return nil, fmt.Errorf("could not open XLSX workbook: %w", err)
+	}
+	var wb struct {
+		Sheets []struct {
+			Name  string     `xml:"name,attr"`
+			Attrs []xml.Attr `xml:",any,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	err = xml.NewDecoder(rc).Decode(&wb)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse XLSX workbook: %w", err)
+	}
+
+	var out []*Table
+	for _, sh := range wb.Sheets {
+		var relID string
+		for _, a := range sh.Attrs {
+			if a.Name.Local == "id" {
+				relID = a.Value
+			}
+		}
+		rel, ok := rels[relID]
+		if !ok {
+			continue
+		}
+		rows, err := readXLSXSheet(&zr.Reader, rel.Target, shared)
+		if err != nil {
+			return nil, fmt.Errorf("could not read sheet %q: %w", sh.Name, err)
+		}
+		if t := newTable(sh.Name, rows); t.Header != nil {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// xlsxSharedStrings reads xl/sharedStrings.xml; a workbook without it has
+// no shared strings.
+func xlsxSharedStrings(zr *zip.Reader) ([]string, error) {
+	if !hasZipPart(zr, "xl/sharedStrings.xml") {
+		return nil, nil
+	}
+	rc, err := openZipPart(zr, "xl/sharedStrings.xml")
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var (
+		out      []string
+		cur      strings.Builder
+		inText   bool
+		phonetic int // inside <rPh>, whose text is a reading aid, not content
+	)
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not parse XLSX shared strings: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				cur.Reset()
+			case "rPh":
+				phonetic++
+			case "t":
+				inText = phonetic == 0
+			}
+		case xml.CharData:
+			if inText {
+				cur.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				out = append(out, cur.String())
+			case "rPh":
+				phonetic--
+			case "t":
+				inText = false
+			}
+		}
+	}
+	return out, nil
+}
+
+// readXLSXSheet streams a worksheet into rows of cell text, placing each
+// cell by its reference ("C7") so gaps in sparse rows are kept.
+func readXLSXSheet(zr *zip.Reader, part string, shared []string) ([][]string, error) {
+	rc, err := openZipPart(zr, part)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var (
+		rows     [][]string
+		row      []string
+		col      int
+		cellType string
+		value    strings.Builder
+		inValue  bool
+	)
+	dec := xml.NewDecoder(rc)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				row, col = nil, 0
+			case "c":
+				if ref := xmlAttr(t, "r"); ref != "" {
+					if c, ok := xlsxColumn(ref); ok {
+						col = c
+					}
+				}
+				cellType = xmlAttr(t, "t")
+				value.Reset()
+			case "v":
+				inValue = true
+			case "t":
+				// <is><t> holds inline strings.
+				inValue = cellType == "inlineStr"
+			}
+		case xml.CharData:
+			if inValue {
+				value.Write(t)
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v", "t":
+				inValue = false
+			case "c":
+				text := xlsxCellText(cellType, value.String(), shared)
+				for len(row) <= col {
+					row = append(row, "")
+				}
+				row[col] = text
+				col++
+			case "row":
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// xlsxCellText turns a cell's stored value into text.
+func xlsxCellText(cellType, v string, shared []string) string {
+	switch cellType {
+	case "s":
+		var idx int
+		if _, err := fmt.Sscan(v, &idx); err == nil && idx >= 0 && idx < len(shared) {
+			return shared[idx]
+		}
+		return ""
+	case "b":
+		if v == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	default:
+		return v
+	}
+}
+
+// xlsxColumn converts the letters of a cell reference ("AB12") to a
+// zero-based column index.
+func xlsxColumn(ref string) (int, bool) {
+	col := 0
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		n++
+	}
+	if n == 0 || n > 3 {
+		return 0, false
+	}
+	return col - 1, true
+}
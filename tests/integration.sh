@@ -10,7 +10,9 @@ set -euo pipefail
 #  5) Upload a dummy file into the bucket
 #  6) List all files in the bucket to verify
 #  7) Create a quiz for that bucket
-#  8) Poll the quiz status until it changes (it will stay "pending" until you implement quiz‐generation logic)
+#  8) Poll the quiz status until it is "ready" or "failed" (standard quizzes are
+#     generated from the bucket's processed files, so without an OpenAI key, or
+#     before dummy.txt is processed, the quiz fails with "no processed files")
 #  9) List attempts for that bucket (should be empty at first)
 # 10) (Optional) Once quiz is "ready", fetch questions and submit an (empty) attempt
 # 11) List attempts again
@@ -101,6 +103,10 @@ while true; do
     echo "   → quiz is ready!"
     break
   fi
+  if [[ "$STATUS" == "failed" ]]; then
+    echo "   → quiz generation failed."
+    break
+  fi
   if (( attempts >= 100 )); then
     echo "   → timed out waiting for quiz to become 'ready'. Continuing anyway."
     break